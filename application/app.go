@@ -12,7 +12,6 @@ import (
 	"gioui.org/op"
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 )
 
 type App struct {
@@ -22,11 +21,10 @@ type App struct {
 
 	*AccessoryMetadataStore
 
-	*material.Theme
+	// values of characteristics shared between widgets
+	State *StateStore
 
-	// to be able to emit value changes
-	// to share state between widgets
-	ee emitter.Emitter
+	*material.Theme
 }
 
 func NewApp(controller *hkontroller.Controller, window *app.Window, router *page.Router, settingsDir string) *App {
@@ -36,8 +34,7 @@ func NewApp(controller *hkontroller.Controller, window *app.Window, router *page
 		Router:                 router,
 		Theme:                  material.NewTheme(gofont.Collection()),
 		AccessoryMetadataStore: NewAccessoryMetadataStore(path.Join(settingsDir, "hkapp", "metadata")),
-
-		ee: emitter.Emitter{},
+		State:                  NewStateStore(window.Invalidate),
 	}
 }
 
@@ -66,17 +63,30 @@ func (a *App) loop() error {
 	}
 }
 
-func (a *App) OnValueChange(deviceId string, aid uint64, iid uint64) <-chan emitter.Event {
-	topic := fmt.Sprintf("value_%s_%d_%d", deviceId, aid, iid)
-	return a.ee.On(topic)
+// WriteValue writes characteristic value optimistically.
+// If device rejects it, snackbar explains what failed and offers to retry.
+func (a *App) WriteValue(dev *hkontroller.Device, aid, iid uint64,
+	value interface{}, writer interface{}, what string) {
+	a.WriteValues(dev, map[CharID]interface{}{{Aid: aid, Iid: iid}: value}, writer, what)
 }
 
-func (a *App) OffValueChange(deviceId string, aid uint64, iid uint64, ch <-chan emitter.Event) {
-	topic := fmt.Sprintf("value_%s_%d_%d", deviceId, aid, iid)
-	a.ee.Off(topic, ch)
-}
-
-func (a *App) EmitValueChange(deviceId string, aid uint64, iid uint64, value interface{}) <-chan struct{} {
-	topic := fmt.Sprintf("value_%s_%d_%d", deviceId, aid, iid)
-	return a.ee.Emit(topic, aid, iid, value)
+// WriteValues is WriteValue for several characteristics written in one batch.
+// Retry writes only values device rejected.
+func (a *App) WriteValues(dev *hkontroller.Device, values map[CharID]interface{},
+	writer interface{}, what string) {
+	a.State.WriteBatch(dev, values, writer, func(errs map[CharID]error) {
+		if len(errs) == 0 {
+			return
+		}
+		failed := make(map[CharID]interface{}, len(errs))
+		var err error
+		for id, e := range errs {
+			failed[id] = values[id]
+			err = e
+		}
+		a.Router.ShowSnackbar(fmt.Sprintf("Cannot set %s: %v", what, err), "Retry", func() {
+			a.WriteValues(dev, failed, writer, what)
+		})
+		a.Window.Invalidate()
+	})
 }
@@ -0,0 +1,235 @@
+package application
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hkontrol/hkontroller"
+)
+
+// CharID identifies characteristic within device
+type CharID struct {
+	Aid uint64
+	Iid uint64
+}
+
+// ReadResult is outcome of background read of single characteristic
+type ReadResult struct {
+	State CharState
+	Err   error
+}
+
+type readRequest struct {
+	ids  []CharID
+	done func(map[CharID]ReadResult)
+}
+
+// readQueue collects read requests for device while previous batch is in flight,
+// so cards built in one Update are filled in by a single batch
+type readQueue struct {
+	dev     *hkontroller.Device
+	reqs    []readRequest
+	running bool
+}
+
+// LoadBatch returns known values at once and reads the rest off the calling goroutine.
+// done is called from background goroutine with results of read characteristics,
+// it is not called if every value was already known.
+func (s *StateStore) LoadBatch(dev *hkontroller.Device, ids []CharID,
+	done func(map[CharID]ReadResult)) map[CharID]CharState {
+	known := make(map[CharID]CharState)
+	var missing []CharID
+
+	s.mu.Lock()
+	for _, id := range ids {
+		e, ok := s.entries[CharKey{Device: dev.Name, Aid: id.Aid, Iid: id.Iid}]
+		if ok && e.known {
+			known[id] = e.state
+			continue
+		}
+		missing = append(missing, id)
+	}
+	s.mu.Unlock()
+
+	if len(missing) > 0 {
+		s.FetchBatch(dev, missing, done)
+	}
+	return known
+}
+
+// FetchBatch reads values of characteristics in background and notifies watchers.
+// Requests made while device is busy with previous batch are merged into the next one.
+func (s *StateStore) FetchBatch(dev *hkontroller.Device, ids []CharID,
+	done func(map[CharID]ReadResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.reads[dev.Name]
+	if !ok {
+		q = &readQueue{dev: dev}
+		s.reads[dev.Name] = q
+	}
+	q.dev = dev
+	q.reqs = append(q.reqs, readRequest{ids: ids, done: done})
+	if !q.running {
+		q.running = true
+		go s.drainReads(q)
+	}
+}
+
+func (s *StateStore) drainReads(q *readQueue) {
+	for {
+		s.mu.Lock()
+		reqs := q.reqs
+		q.reqs = nil
+		dev := q.dev
+		if len(reqs) == 0 {
+			q.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		// the same characteristic is often asked by quick and full cards
+		seen := make(map[CharID]bool)
+		var ids []CharID
+		for _, r := range reqs {
+			for _, id := range r.ids {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		results := s.readChars(dev, ids)
+		for id, res := range results {
+			if res.Err != nil {
+				// reported to requester through done
+				continue
+			}
+			res.State = s.update(dev, id.Aid, id.Iid, res.State.Value.Raw(), SourcePoll, nil, false)
+			results[id] = res
+		}
+
+		for _, r := range reqs {
+			if r.done == nil {
+				continue
+			}
+			own := make(map[CharID]ReadResult, len(r.ids))
+			for _, id := range r.ids {
+				own[id] = results[id]
+			}
+			r.done(own)
+		}
+		if s.onUpdate != nil {
+			s.onUpdate()
+		}
+	}
+}
+
+// WriteBatch is Write for several characteristics of device.
+// done, if not nil, gets errors of failed writes, empty map if all succeeded.
+func (s *StateStore) WriteBatch(dev *hkontroller.Device, values map[CharID]interface{},
+	writer interface{}, done func(map[CharID]error)) {
+	seqs := make(map[CharID]uint64, len(values))
+	s.mu.Lock()
+	for id := range values {
+		e := s.entry(dev, id.Aid, id.Iid)
+		e.writeSeq++
+		seqs[id] = e.writeSeq
+	}
+	s.mu.Unlock()
+
+	for id, v := range values {
+		s.update(dev, id.Aid, id.Iid, v, SourceWrite, writer, true)
+	}
+
+	go func() {
+		errs := s.writeChars(dev, values)
+		for id, v := range values {
+			s.finishWrite(dev, id, seqs[id], v, writer, errs[id])
+		}
+		if done != nil {
+			done(errs)
+		}
+	}()
+}
+
+// readChars and writeChars pass batch to transport accessory by accessory,
+// in order of aid
+
+func (s *StateStore) readChars(dev *hkontroller.Device, ids []CharID) map[CharID]ReadResult {
+	res := make(map[CharID]ReadResult, len(ids))
+	now := time.Now()
+	for _, aid := range sortedAids(ids) {
+		var iids []uint64
+		for _, id := range sortedIDs(ids) {
+			if id.Aid == aid {
+				iids = append(iids, id.Iid)
+			}
+		}
+		values, errs := s.transport.getChars(dev, aid, iids)
+		for _, iid := range iids {
+			id := CharID{Aid: aid, Iid: iid}
+			if err := errs[iid]; err != nil {
+				res[id] = ReadResult{Err: err}
+				continue
+			}
+			res[id] = ReadResult{State: CharState{Value: ValueOf(values[iid]), Source: SourcePoll, Updated: now}}
+		}
+	}
+	return res
+}
+
+func (s *StateStore) writeChars(dev *hkontroller.Device, values map[CharID]interface{}) map[CharID]error {
+	ids := make([]CharID, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	errs := make(map[CharID]error)
+	for _, aid := range sortedAids(ids) {
+		own := make(map[uint64]interface{})
+		for id, v := range values {
+			if id.Aid == aid {
+				own[id.Iid] = v
+			}
+		}
+		for iid, err := range s.transport.putChars(dev, aid, own) {
+			if err != nil {
+				errs[CharID{Aid: aid, Iid: iid}] = err
+			}
+		}
+	}
+	return errs
+}
+
+func sortedIDs(ids []CharID) []CharID {
+	sorted := make([]CharID, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Aid != sorted[j].Aid {
+			return sorted[i].Aid < sorted[j].Aid
+		}
+		return sorted[i].Iid < sorted[j].Iid
+	})
+	return sorted
+}
+
+func sortedAids(ids []CharID) []uint64 {
+	var aids []uint64
+	for _, id := range sortedIDs(ids) {
+		if len(aids) == 0 || aids[len(aids)-1] != id.Aid {
+			aids = append(aids, id.Aid)
+		}
+	}
+	return aids
+}
+
+func sortedIids(values map[uint64]interface{}) []uint64 {
+	iids := make([]uint64, 0, len(values))
+	for iid := range values {
+		iids = append(iids, iid)
+	}
+	sort.Slice(iids, func(i, j int) bool { return iids[i] < iids[j] })
+	return iids
+}
@@ -60,7 +60,7 @@ func (c *AccessoryMetadataStore) Save(deviceId string, aid uint64, metadata map[
 		return err
 	}
 
-	file, err := os.OpenFile(c.getPathForAcc(deviceId, aid), os.O_WRONLY|os.O_CREATE, 0666)
+	file, err := os.OpenFile(c.getPathForAcc(deviceId, aid), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
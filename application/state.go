@@ -0,0 +1,365 @@
+package application
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hkontrol/hkontroller"
+	"github.com/olebedev/emitter"
+)
+
+// CharKey identifies characteristic among all devices
+type CharKey struct {
+	Device string
+	Aid    uint64
+	Iid    uint64
+}
+
+// CharState is last known value of characteristic
+type CharState struct {
+	Value  Value
+	Source Source
+	// Pending is set for value written by app but not confirmed by device yet
+	Pending bool
+	Updated time.Time
+}
+
+// WatchID identifies value watcher registered in StateStore
+type WatchID uint64
+
+type watch struct {
+	key    CharKey
+	events bool
+}
+
+type charEntry struct {
+	dev *hkontroller.Device
+
+	state CharState
+	known bool
+
+	// last value accepted by device, restored when write fails
+	confirmed      CharState
+	knownConfirmed bool
+	// incremented on every write, so only the latest one is confirmed or rolled back
+	writeSeq uint64
+	// sequence number of the latest write accepted by device
+	confirmedSeq uint64
+
+	// single HAP subscription shared by all watchers
+	hapEvents <-chan emitter.Event
+	// watchers asked for HAP events
+	evWatchers int
+	// characteristic carries momentary events, its value is never refreshed by reading
+	eventOnly bool
+	// characteristic cannot be read, e.g. RemoteKey
+	writeOnly bool
+
+	watchers map[WatchID]func(ValueEvent)
+}
+
+// StateStore keeps characteristic values of all accessories.
+// It owns single HAP subscription per characteristic
+// and fans value changes out to any number of widgets.
+type StateStore struct {
+	mu      sync.Mutex
+	entries map[CharKey]*charEntry
+	watches map[WatchID]watch
+	lastID  WatchID
+
+	// background reads, by device name
+	reads map[string]*readQueue
+
+	// onUpdate is called after watchers were notified, e.g. to redraw window
+	onUpdate func()
+
+	transport transport
+}
+
+func NewStateStore(onUpdate func()) *StateStore {
+	return &StateStore{
+		entries:  make(map[CharKey]*charEntry),
+		watches:  make(map[WatchID]watch),
+		reads:    make(map[string]*readQueue),
+		onUpdate: onUpdate,
+
+		transport: hkTransport{},
+	}
+}
+
+func (s *StateStore) entry(dev *hkontroller.Device, aid, iid uint64) *charEntry {
+	key := CharKey{Device: dev.Name, Aid: aid, Iid: iid}
+	e, ok := s.entries[key]
+	if !ok {
+		e = &charEntry{
+			dev:      dev,
+			watchers: make(map[WatchID]func(ValueEvent)),
+		}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// Get returns last known value
+func (s *StateStore) Get(dev *hkontroller.Device, aid, iid uint64) (CharState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[CharKey{Device: dev.Name, Aid: aid, Iid: iid}]
+	if !ok || !e.known {
+		return CharState{}, false
+	}
+	return e.state, true
+}
+
+// Invalidate forgets values of device, e.g. when its connection is closed,
+// so cards built later read them again instead of showing stale ones.
+// Subscriptions died with connection and are dropped, watchers are kept
+// and get events again after Resubscribe or next Watch of characteristic.
+func (s *StateStore) Invalidate(dev *hkontroller.Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if key.Device != dev.Name {
+			continue
+		}
+		e.known = false
+		e.knownConfirmed = false
+		e.hapEvents = nil
+	}
+}
+
+// MarkEventOnly tells store that characteristic, e.g. ProgrammableSwitchEvent,
+// carries momentary events. Reading it returns null or the last event,
+// so it is not refreshed on resubscribe.
+func (s *StateStore) MarkEventOnly(dev *hkontroller.Device, aid, iid uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(dev, aid, iid).eventOnly = true
+}
+
+// MarkWriteOnly tells store that characteristic cannot be read,
+// so value of failed write is not asked from device.
+func (s *StateStore) MarkWriteOnly(dev *hkontroller.Device, aid, iid uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(dev, aid, iid).writeOnly = true
+}
+
+// Fetch requests actual value from device and notifies watchers
+func (s *StateStore) Fetch(dev *hkontroller.Device, aid, iid uint64) (CharState, error) {
+	id := CharID{Aid: aid, Iid: iid}
+	res := s.readChars(dev, []CharID{id})[id]
+	if res.Err != nil {
+		return CharState{}, res.Err
+	}
+	return s.update(dev, aid, iid, res.State.Value.Raw(), SourcePoll, nil, false), nil
+}
+
+// finishWrite confirms or rolls back value written with given sequence number.
+// Writes may finish out of order, older write never overrides newer confirmed one.
+func (s *StateStore) finishWrite(dev *hkontroller.Device, id CharID, seq uint64,
+	value interface{}, writer interface{}, err error) {
+	s.mu.Lock()
+	e := s.entry(dev, id.Aid, id.Iid)
+	latest := e.writeSeq == seq
+	prev, knownPrev := e.confirmed, e.knownConfirmed
+	writeOnly := e.writeOnly
+	newer := err == nil && seq > e.confirmedSeq
+	if newer {
+		e.confirmedSeq = seq
+		e.confirmed = CharState{Value: ValueOf(value), Source: SourceWrite, Updated: time.Now()}
+		e.knownConfirmed = true
+	}
+	// while newer write is in flight it decides what is shown
+	show := newer && (latest || !e.state.Pending)
+	s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		if show {
+			s.update(dev, id.Aid, id.Iid, value, SourceWrite, writer, false)
+		}
+	case !latest:
+	case knownPrev:
+		s.update(dev, id.Aid, id.Iid, prev.Value.Raw(), SourceRollback, writer, false)
+	case writeOnly:
+		// nothing to restore and nothing to read, pending value is dropped
+		s.update(dev, id.Aid, id.Iid, nil, SourceRollback, writer, false)
+	default:
+		// nothing to restore, ask device what it has.
+		// If it does not answer, value stays pending until next event or read.
+		_, _ = s.Fetch(dev, id.Aid, id.Iid)
+	}
+}
+
+// update stores value and notifies watchers
+func (s *StateStore) update(dev *hkontroller.Device, aid, iid uint64,
+	value interface{}, source Source, writer interface{}, pending bool) CharState {
+	ev := ValueEvent{
+		Key:     CharKey{Device: dev.Name, Aid: aid, Iid: iid},
+		Value:   ValueOf(value),
+		Source:  source,
+		Writer:  writer,
+		Pending: pending,
+		Time:    time.Now(),
+	}
+
+	s.mu.Lock()
+	e := s.entry(dev, aid, iid)
+	e.state = CharState{Value: ev.Value, Source: source, Pending: pending, Updated: ev.Time}
+	e.known = true
+	if !pending {
+		e.confirmed = e.state
+		e.knownConfirmed = true
+	}
+	st := e.state
+	watchers := make([]func(ValueEvent), 0, len(e.watchers))
+	for _, fn := range e.watchers {
+		watchers = append(watchers, fn)
+	}
+	s.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(ev)
+	}
+	if len(watchers) > 0 && s.onUpdate != nil {
+		s.onUpdate()
+	}
+	return st
+}
+
+// Watch registers fn to be called on every value change.
+// If events is true, store subscribes to HAP events of characteristic,
+// shared with other watchers. Otherwise only changes made by app are watched.
+func (s *StateStore) Watch(dev *hkontroller.Device, aid, iid uint64,
+	events bool, fn func(ValueEvent)) WatchID {
+	s.mu.Lock()
+	s.lastID++
+	id := s.lastID
+	key := CharKey{Device: dev.Name, Aid: aid, Iid: iid}
+	e := s.entry(dev, aid, iid)
+	e.watchers[id] = fn
+	s.watches[id] = watch{key: key, events: events}
+
+	subscribe := false
+	if events {
+		e.evWatchers++
+		// the first watcher subscribes, later ones renew subscription
+		// dropped with connection, on device they were given
+		if e.hapEvents == nil {
+			e.dev = dev
+			subscribe = true
+		}
+	}
+	s.mu.Unlock()
+
+	if subscribe {
+		s.subscribe(key, e, dev)
+	}
+	return id
+}
+
+// subscribe makes HAP subscription of entry. It talks to device, so s.mu must not be held.
+// Subscription is dropped if watchers are gone or device changed meanwhile.
+func (s *StateStore) subscribe(key CharKey, e *charEntry, dev *hkontroller.Device) {
+	ev, err := s.transport.subscribe(dev, key.Aid, key.Iid)
+	if err != nil {
+		// watchers still get values read and written by app
+		return
+	}
+	s.mu.Lock()
+	keep := e.evWatchers > 0 && e.hapEvents == nil && e.dev == dev
+	if keep {
+		e.hapEvents = ev
+	}
+	s.mu.Unlock()
+	if !keep {
+		_ = s.transport.unsubscribe(dev, key.Aid, key.Iid, ev)
+		return
+	}
+
+	go func() {
+		// hkontroller emits aid, iid and value,
+		// this is the only place where its untyped events are unpacked
+		for ee := range ev {
+			if len(ee.Args) < 3 {
+				continue
+			}
+			s.update(dev, key.Aid, key.Iid, ee.Args[2], SourceHAP, nil, false)
+		}
+		// channel is closed on unsubscribe or when connection is lost
+		s.mu.Lock()
+		if e.hapEvents == ev {
+			e.hapEvents = nil
+		}
+		s.mu.Unlock()
+	}()
+}
+
+// Resubscribe renews HAP subscriptions of device, which forgets them on reconnect,
+// and refreshes watched values possibly missed while it was away
+func (s *StateStore) Resubscribe(dev *hkontroller.Device) {
+	type renewal struct {
+		key    CharKey
+		e      *charEntry
+		oldDev *hkontroller.Device
+		old    <-chan emitter.Event
+	}
+
+	s.mu.Lock()
+	var renewals []renewal
+	var ids []CharID
+	for key, e := range s.entries {
+		if key.Device != dev.Name || e.evWatchers == 0 {
+			continue
+		}
+		renewals = append(renewals, renewal{key: key, e: e, oldDev: e.dev, old: e.hapEvents})
+		e.hapEvents = nil
+		e.dev = dev
+		if !e.eventOnly {
+			ids = append(ids, CharID{Aid: key.Aid, Iid: key.Iid})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, r := range renewals {
+		if r.old != nil {
+			// old subscription is dead anyway
+			_ = s.transport.unsubscribe(r.oldDev, r.key.Aid, r.key.Iid, r.old)
+		}
+		s.subscribe(r.key, r.e, dev)
+	}
+	if len(ids) > 0 {
+		s.FetchBatch(dev, ids, nil)
+	}
+}
+
+// Unwatch removes watcher. HAP subscription is removed with the last watcher of events.
+func (s *StateStore) Unwatch(id WatchID) {
+	s.mu.Lock()
+	w, ok := s.watches[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.watches, id)
+	key := w.key
+	e := s.entries[key]
+	delete(e.watchers, id)
+
+	var ch <-chan emitter.Event
+	dev := e.dev
+	if w.events {
+		e.evWatchers--
+		if e.evWatchers == 0 {
+			ch = e.hapEvents
+			e.hapEvents = nil
+		}
+	}
+	s.mu.Unlock()
+
+	if ch != nil {
+		// device may be gone already, subscription is forgotten either way
+		_ = s.transport.unsubscribe(dev, key.Aid, key.Iid, ch)
+	}
+}
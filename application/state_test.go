@@ -0,0 +1,526 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hkontrol/hkontroller"
+	"github.com/olebedev/emitter"
+)
+
+type putCall struct {
+	values map[uint64]interface{}
+	reply  chan error
+}
+
+// fakeTransport answers reads from values and logs every request as "<device> <op> ...".
+// Devices are logged by name unless they are given one in names.
+// If puts is set, writes wait there until test replies to them.
+type fakeTransport struct {
+	mu     sync.Mutex
+	values map[CharID]interface{}
+	fail   map[CharID]error
+	names  map[*hkontroller.Device]string
+	subs   map[CharID]chan emitter.Event
+	calls  []string
+	puts   chan putCall
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		values: make(map[CharID]interface{}),
+		fail:   make(map[CharID]error),
+		names:  make(map[*hkontroller.Device]string),
+		subs:   make(map[CharID]chan emitter.Event),
+	}
+}
+
+func (f *fakeTransport) log(dev *hkontroller.Device, format string, args ...interface{}) {
+	name, ok := f.names[dev]
+	if !ok {
+		name = dev.Name
+	}
+	f.calls = append(f.calls, name+" "+fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTransport) getChars(dev *hkontroller.Device, aid uint64,
+	iids []uint64) (map[uint64]interface{}, map[uint64]error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log(dev, "get %d %v", aid, iids)
+	values := make(map[uint64]interface{})
+	errs := make(map[uint64]error)
+	for _, iid := range iids {
+		v, ok := f.values[CharID{Aid: aid, Iid: iid}]
+		if !ok {
+			errs[iid] = errors.New("not readable")
+			continue
+		}
+		values[iid] = v
+	}
+	return values, errs
+}
+
+func (f *fakeTransport) putChars(dev *hkontroller.Device, aid uint64,
+	values map[uint64]interface{}) map[uint64]error {
+	f.mu.Lock()
+	f.log(dev, "put %d %v", aid, sortedIids(values))
+	f.mu.Unlock()
+
+	errs := make(map[uint64]error)
+	if f.puts != nil {
+		c := putCall{values: values, reply: make(chan error)}
+		f.puts <- c
+		if err := <-c.reply; err != nil {
+			for iid := range values {
+				errs[iid] = err
+			}
+		}
+		return errs
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for iid, v := range values {
+		id := CharID{Aid: aid, Iid: iid}
+		if err := f.fail[id]; err != nil {
+			errs[iid] = err
+			continue
+		}
+		f.values[id] = v
+	}
+	return errs
+}
+
+func (f *fakeTransport) subscribe(dev *hkontroller.Device, aid, iid uint64) (<-chan emitter.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log(dev, "sub %d.%d", aid, iid)
+	ch := make(chan emitter.Event, 1)
+	f.subs[CharID{Aid: aid, Iid: iid}] = ch
+	return ch, nil
+}
+
+func (f *fakeTransport) unsubscribe(dev *hkontroller.Device, aid, iid uint64, ch <-chan emitter.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log(dev, "unsub %d.%d", aid, iid)
+	id := CharID{Aid: aid, Iid: iid}
+	// like hkontroller, close channel of subscription
+	if sub, ok := f.subs[id]; ok && (<-chan emitter.Event)(sub) == ch {
+		close(sub)
+		delete(f.subs, id)
+	}
+	return nil
+}
+
+// emit sends HAP event to current subscription of characteristic
+func (f *fakeTransport) emit(t *testing.T, id CharID, value interface{}) {
+	f.mu.Lock()
+	ch, ok := f.subs[id]
+	f.mu.Unlock()
+	if !ok {
+		t.Fatalf("%d.%d is not subscribed", id.Aid, id.Iid)
+	}
+	ch <- emitter.Event{Args: []interface{}{id.Aid, id.Iid, value}}
+}
+
+// waitCalls waits until n requests are made and returns them, clearing the log
+func (f *fakeTransport) waitCalls(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.Lock()
+		if len(f.calls) >= n || time.Now().After(deadline) {
+			calls := f.calls
+			f.calls = nil
+			f.mu.Unlock()
+			if len(calls) != n {
+				t.Fatalf("got %d requests %q, want %d", len(calls), calls, n)
+			}
+			return calls
+		}
+		f.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// nextValue waits for watcher to report value
+func nextValue(t *testing.T, values <-chan string) string {
+	t.Helper()
+	select {
+	case v := <-values:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("watcher got nothing")
+		return ""
+	}
+}
+
+func newTestStore(ft *fakeTransport) *StateStore {
+	s := NewStateStore(nil)
+	s.transport = ft
+	return s
+}
+
+func TestStateStoreSplitsByAccessory(t *testing.T) {
+	ft := newFakeTransport()
+	ft.values[CharID{Aid: 1, Iid: 10}] = true
+	ft.values[CharID{Aid: 1, Iid: 11}] = 50
+	ft.values[CharID{Aid: 2, Iid: 20}] = "hdmi"
+	s := newTestStore(ft)
+	dev := &hkontroller.Device{Name: "dev"}
+
+	read := make(chan map[CharID]ReadResult, 1)
+	s.FetchBatch(dev, []CharID{{Aid: 2, Iid: 21}, {Aid: 1, Iid: 11}, {Aid: 2, Iid: 20}, {Aid: 1, Iid: 10}},
+		func(res map[CharID]ReadResult) { read <- res })
+	res := <-read
+	want := []string{"dev get 1 [10 11]", "dev get 2 [20 21]"}
+	if calls := ft.waitCalls(t, 2); fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("reads %q, want %q", calls, want)
+	}
+	if v, _ := res[CharID{Aid: 1, Iid: 11}].State.Value.Float(); v != 50 {
+		t.Errorf("1.11 = %v, want 50", v)
+	}
+	if str, _ := res[CharID{Aid: 2, Iid: 20}].State.Value.Text(); str != "hdmi" {
+		t.Errorf("2.20 = %q, want hdmi", str)
+	}
+	if res[CharID{Aid: 2, Iid: 21}].Err == nil {
+		t.Error("2.21 read without error")
+	}
+
+	errRejected := errors.New("rejected")
+	ft.fail[CharID{Aid: 2, Iid: 22}] = errRejected
+	written := make(chan map[CharID]error, 1)
+	s.WriteBatch(dev, map[CharID]interface{}{
+		{Aid: 2, Iid: 22}: 1,
+		{Aid: 1, Iid: 10}: false,
+		{Aid: 2, Iid: 20}: "tv",
+	}, nil, func(errs map[CharID]error) { written <- errs })
+	errs := <-written
+	// rejected value had nothing to roll back to, so it is read again
+	want = []string{"dev put 1 [10]", "dev put 2 [20 22]", "dev get 2 [22]"}
+	if calls := ft.waitCalls(t, 3); fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("writes %q, want %q", calls, want)
+	}
+	if len(errs) != 1 || errs[CharID{Aid: 2, Iid: 22}] != errRejected {
+		t.Errorf("write errors %v, want only 2.22 rejected", errs)
+	}
+}
+
+func TestStateStoreInvalidate(t *testing.T) {
+	ft := newFakeTransport()
+	s := newTestStore(ft)
+	closed := &hkontroller.Device{Name: "closed"}
+	alive := &hkontroller.Device{Name: "alive"}
+	s.update(closed, 1, 10, true, SourcePoll, nil, false)
+	s.update(alive, 1, 10, true, SourcePoll, nil, false)
+
+	s.Invalidate(closed)
+	if _, ok := s.Get(closed, 1, 10); ok {
+		t.Error("value of closed device is still known")
+	}
+	if _, ok := s.Get(alive, 1, 10); !ok {
+		t.Error("value of other device is forgotten")
+	}
+
+	ft.values[CharID{Aid: 1, Iid: 10}] = false
+	read := make(chan map[CharID]ReadResult, 1)
+	known := s.LoadBatch(closed, []CharID{{Aid: 1, Iid: 10}}, func(res map[CharID]ReadResult) { read <- res })
+	if len(known) != 0 {
+		t.Errorf("LoadBatch returned stale values %v", known)
+	}
+	if v := (<-read)[CharID{Aid: 1, Iid: 10}].State.Value; v.Bool() {
+		t.Errorf("read %v, want false", v)
+	}
+	ft.waitCalls(t, 1)
+}
+
+func TestStateStoreWatchRefcount(t *testing.T) {
+	ft := newFakeTransport()
+	s := newTestStore(ft)
+	dev := &hkontroller.Device{Name: "dev"}
+	id := CharID{Aid: 1, Iid: 10}
+
+	got := make(chan string, 3)
+	watcher := func(name string) func(ValueEvent) {
+		return func(ev ValueEvent) { got <- fmt.Sprintf("%s %s %s", name, ev.Source, ev.Value) }
+	}
+
+	plain := s.Watch(dev, id.Aid, id.Iid, false, watcher("plain"))
+	ft.waitCalls(t, 0)
+	first := s.Watch(dev, id.Aid, id.Iid, true, watcher("first"))
+	if calls := ft.waitCalls(t, 1); calls[0] != "dev sub 1.10" {
+		t.Errorf("first events watcher made %q", calls)
+	}
+	second := s.Watch(dev, id.Aid, id.Iid, true, watcher("second"))
+	ft.waitCalls(t, 0)
+
+	// all watchers share one subscription
+	ft.emit(t, id, 42)
+	var delivered []string
+	for i := 0; i < 3; i++ {
+		delivered = append(delivered, nextValue(t, got))
+	}
+	sort.Strings(delivered)
+	if want := "[first hap 42 plain hap 42 second hap 42]"; fmt.Sprint(delivered) != want {
+		t.Errorf("event delivered as %v, want %v", delivered, want)
+	}
+
+	s.Unwatch(first)
+	s.Unwatch(plain)
+	ft.waitCalls(t, 0)
+	s.Unwatch(second)
+	if calls := ft.waitCalls(t, 1); calls[0] != "dev unsub 1.10" {
+		t.Errorf("last events watcher made %q", calls)
+	}
+	s.Unwatch(second)
+	ft.waitCalls(t, 0)
+}
+
+func TestStateStoreResubscribe(t *testing.T) {
+	ft := newFakeTransport()
+	s := newTestStore(ft)
+	old := &hkontroller.Device{Name: "dev"}
+	ft.names[old] = "old"
+
+	values := make(chan string, 2)
+	s.MarkEventOnly(old, 1, 11)
+	s.Watch(old, 1, 10, true, func(ev ValueEvent) { values <- fmt.Sprintf("%s %s", ev.Source, ev.Value) })
+	s.Watch(old, 1, 11, true, func(ValueEvent) {})
+	s.Watch(old, 1, 12, false, func(ValueEvent) {})
+	ft.waitCalls(t, 2)
+
+	// device reconnects as new object with the same name
+	reconnected := &hkontroller.Device{Name: "dev"}
+	ft.names[reconnected] = "new"
+	ft.values[CharID{Aid: 1, Iid: 10}] = 22.5
+	s.Resubscribe(reconnected)
+
+	calls := ft.waitCalls(t, 5)
+	// values are refreshed after subscriptions are renewed
+	if last := calls[len(calls)-1]; last != "new get 1 [10]" {
+		t.Errorf("last request %q, want read of watched value", last)
+	}
+	sort.Strings(calls)
+	want := []string{"new get 1 [10]", "new sub 1.10", "new sub 1.11", "old unsub 1.10", "old unsub 1.11"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("requests %q, want %q", calls, want)
+	}
+
+	if got := nextValue(t, values); got != "poll 22.5" {
+		t.Errorf("watcher got %q after resubscribe, want refreshed value", got)
+	}
+	ft.emit(t, CharID{Aid: 1, Iid: 10}, 23)
+	if got := nextValue(t, values); got != "hap 23" {
+		t.Errorf("watcher got %q, want event of new subscription", got)
+	}
+}
+
+func TestStateStoreWriteOrdering(t *testing.T) {
+	errRejected := errors.New("rejected")
+	type finish struct {
+		write int // 1 or 2
+		err   error
+	}
+	tests := []struct {
+		name   string
+		finish []finish
+		want   float64
+		source Source
+		events []string
+	}{
+		{
+			name:   "in order",
+			finish: []finish{{1, nil}, {2, nil}},
+			want:   2, source: SourceWrite,
+			events: []string{"write 1 pending", "write 2 pending", "write 2"},
+		},
+		{
+			name:   "older finishes last",
+			finish: []finish{{2, nil}, {1, nil}},
+			want:   2, source: SourceWrite,
+			events: []string{"write 1 pending", "write 2 pending", "write 2"},
+		},
+		{
+			name:   "latest fails after older confirmed",
+			finish: []finish{{1, nil}, {2, errRejected}},
+			want:   1, source: SourceRollback,
+			events: []string{"write 1 pending", "write 2 pending", "rollback 1"},
+		},
+		{
+			name:   "older confirmed after latest failed",
+			finish: []finish{{2, errRejected}, {1, nil}},
+			want:   1, source: SourceWrite,
+			events: []string{"write 1 pending", "write 2 pending", "rollback 0", "write 1"},
+		},
+		{
+			name:   "older fails, latest confirmed",
+			finish: []finish{{1, errRejected}, {2, nil}},
+			want:   2, source: SourceWrite,
+			events: []string{"write 1 pending", "write 2 pending", "write 2"},
+		},
+		{
+			name:   "latest confirmed, older fails",
+			finish: []finish{{2, nil}, {1, errRejected}},
+			want:   2, source: SourceWrite,
+			events: []string{"write 1 pending", "write 2 pending", "write 2"},
+		},
+		{
+			name:   "both fail",
+			finish: []finish{{1, errRejected}, {2, errRejected}},
+			want:   0, source: SourceRollback,
+			events: []string{"write 1 pending", "write 2 pending", "rollback 0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := newFakeTransport()
+			ft.puts = make(chan putCall)
+			s := newTestStore(ft)
+			dev := &hkontroller.Device{Name: "dev"}
+			id := CharID{Aid: 1, Iid: 10}
+			writer := new(int)
+
+			s.update(dev, id.Aid, id.Iid, 0, SourcePoll, nil, false)
+			var events []string
+			s.Watch(dev, id.Aid, id.Iid, false, func(ev ValueEvent) {
+				e := fmt.Sprintf("%s %s", ev.Source, ev.Value)
+				if ev.Pending {
+					e += " pending"
+				}
+				events = append(events, e)
+			})
+
+			done := make(chan struct{})
+			calls := make(map[int]putCall)
+			for w := 1; w <= 2; w++ {
+				s.WriteBatch(dev, map[CharID]interface{}{id: w}, writer, func(map[CharID]error) {
+					done <- struct{}{}
+				})
+				select {
+				case c := <-ft.puts:
+					calls[w] = c
+				case <-time.After(time.Second):
+					t.Fatalf("write %d was not sent", w)
+				}
+			}
+
+			st, _ := s.Get(dev, id.Aid, id.Iid)
+			if v, _ := st.Value.Float(); v != 2 || !st.Pending {
+				t.Fatalf("before finish: value %v pending %v, want 2 pending", v, st.Pending)
+			}
+
+			for _, f := range tt.finish {
+				calls[f.write].reply <- f.err
+				<-done
+			}
+
+			st, _ = s.Get(dev, id.Aid, id.Iid)
+			if v, _ := st.Value.Float(); v != tt.want || st.Source != tt.source || st.Pending {
+				t.Errorf("got %v %v pending %v, want %v %v", v, st.Source, st.Pending, tt.want, tt.source)
+			}
+			if fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("events %q, want %q", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestStateStoreWatchEcho(t *testing.T) {
+	ft := newFakeTransport()
+	ft.puts = make(chan putCall)
+	s := newTestStore(ft)
+	dev := &hkontroller.Device{Name: "dev"}
+	card, other := new(int), new(int)
+
+	var echoes []bool
+	s.Watch(dev, 1, 10, false, func(ev ValueEvent) {
+		echoes = append(echoes, ev.Echo(card))
+	})
+
+	done := make(chan struct{})
+	s.WriteBatch(dev, map[CharID]interface{}{{Aid: 1, Iid: 10}: true}, card, func(map[CharID]error) {
+		close(done)
+	})
+	(<-ft.puts).reply <- nil
+	<-done
+	s.update(dev, 1, 10, false, SourceHAP, nil, false)
+	s.update(dev, 1, 10, true, SourceWrite, other, false)
+
+	want := []bool{true, true, false, false}
+	if fmt.Sprint(echoes) != fmt.Sprint(want) {
+		t.Errorf("echoes %v, want %v", echoes, want)
+	}
+}
+
+func TestStateStoreReconnect(t *testing.T) {
+	ft := newFakeTransport()
+	s := newTestStore(ft)
+	id := CharID{Aid: 1, Iid: 10}
+	old := &hkontroller.Device{Name: "dev"}
+	ft.names[old] = "old"
+
+	// card on accessories page and opened accessory page watch the same value
+	card := s.Watch(old, id.Aid, id.Iid, true, func(ValueEvent) {})
+	page := make(chan string, 2)
+	s.Watch(old, id.Aid, id.Iid, true, func(ev ValueEvent) { page <- fmt.Sprintf("%s %s", ev.Source, ev.Value) })
+	ft.waitCalls(t, 1)
+
+	s.Invalidate(old)
+
+	// device comes back as new object, page rebuilds card and resubscribes device
+	reconnected := &hkontroller.Device{Name: "dev"}
+	ft.names[reconnected] = "new"
+	ft.values[id] = 18
+	s.Unwatch(card)
+	ft.waitCalls(t, 0)
+	rebuilt := make(chan string, 2)
+	s.Watch(reconnected, id.Aid, id.Iid, true, func(ev ValueEvent) { rebuilt <- fmt.Sprintf("%s %s", ev.Source, ev.Value) })
+	if calls := ft.waitCalls(t, 1); calls[0] != "new sub 1.10" {
+		t.Errorf("rebuilt card made %q, want subscription on reconnected device", calls)
+	}
+	s.Resubscribe(reconnected)
+	want := []string{"new unsub 1.10", "new sub 1.10", "new get 1 [10]"}
+	if calls := ft.waitCalls(t, 3); fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("resubscribe made %q, want %q", calls, want)
+	}
+
+	for _, values := range []chan string{page, rebuilt} {
+		if got := nextValue(t, values); got != "poll 18" {
+			t.Errorf("watcher got %q, want refreshed value", got)
+		}
+	}
+	ft.emit(t, id, 19)
+	for _, values := range []chan string{page, rebuilt} {
+		if got := nextValue(t, values); got != "hap 19" {
+			t.Errorf("watcher got %q, want event of reconnected device", got)
+		}
+	}
+}
+
+func TestStateStoreWriteOnlyRejected(t *testing.T) {
+	ft := newFakeTransport()
+	s := newTestStore(ft)
+	dev := &hkontroller.Device{Name: "dev"}
+	remoteKey := CharID{Aid: 1, Iid: 30}
+	ft.fail[remoteKey] = errors.New("rejected")
+	s.MarkWriteOnly(dev, remoteKey.Aid, remoteKey.Iid)
+
+	done := make(chan struct{})
+	s.WriteBatch(dev, map[CharID]interface{}{remoteKey: 4}, nil, func(map[CharID]error) { close(done) })
+	<-done
+
+	// value is not read back, so it must not stay pending
+	if calls := ft.waitCalls(t, 1); calls[0] != "dev put 1 [30]" {
+		t.Errorf("requests %q, want only write", calls)
+	}
+	st, _ := s.Get(dev, remoteKey.Aid, remoteKey.Iid)
+	if st.Pending || st.Source != SourceRollback || !st.Value.IsNil() {
+		t.Errorf("got %v %v pending %v, want nil rollback", st.Value, st.Source, st.Pending)
+	}
+}
@@ -0,0 +1,56 @@
+package application
+
+import (
+	"github.com/hkontrol/hkontroller"
+	"github.com/olebedev/emitter"
+)
+
+// transport carries characteristic requests of StateStore to devices,
+// tests replace it with fake one
+type transport interface {
+	// getChars reads characteristics of single accessory
+	getChars(dev *hkontroller.Device, aid uint64, iids []uint64) (map[uint64]interface{}, map[uint64]error)
+	// putChars writes characteristics of single accessory
+	putChars(dev *hkontroller.Device, aid uint64, values map[uint64]interface{}) map[uint64]error
+	subscribe(dev *hkontroller.Device, aid, iid uint64) (<-chan emitter.Event, error)
+	unsubscribe(dev *hkontroller.Device, aid, iid uint64, ch <-chan emitter.Event) error
+}
+
+// hkTransport talks to devices through hkontroller.
+// hkontroller has no requests for several characteristics,
+// so each one is read and written by its own request, in order of iid.
+type hkTransport struct{}
+
+func (hkTransport) getChars(dev *hkontroller.Device, aid uint64,
+	iids []uint64) (map[uint64]interface{}, map[uint64]error) {
+	values := make(map[uint64]interface{}, len(iids))
+	errs := make(map[uint64]error)
+	for _, iid := range iids {
+		descr, err := dev.GetCharacteristic(aid, iid)
+		if err != nil {
+			errs[iid] = err
+			continue
+		}
+		values[iid] = descr.Value
+	}
+	return values, errs
+}
+
+func (hkTransport) putChars(dev *hkontroller.Device, aid uint64,
+	values map[uint64]interface{}) map[uint64]error {
+	errs := make(map[uint64]error)
+	for _, iid := range sortedIids(values) {
+		if err := dev.PutCharacteristic(aid, iid, values[iid]); err != nil {
+			errs[iid] = err
+		}
+	}
+	return errs
+}
+
+func (hkTransport) subscribe(dev *hkontroller.Device, aid, iid uint64) (<-chan emitter.Event, error) {
+	return dev.SubscribeToEvents(aid, iid)
+}
+
+func (hkTransport) unsubscribe(dev *hkontroller.Device, aid, iid uint64, ch <-chan emitter.Event) error {
+	return dev.UnsubscribeFromEvents(aid, iid, ch)
+}
@@ -0,0 +1,157 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Source tells where characteristic value came from
+type Source int
+
+const (
+	SourcePoll     Source = iota // value read from device
+	SourceHAP                    // HAP event notification
+	SourceWrite                  // value written by app
+	SourceRollback               // write failed, previous value restored
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourcePoll:
+		return "poll"
+	case SourceHAP:
+		return "hap"
+	case SourceWrite:
+		return "write"
+	case SourceRollback:
+		return "rollback"
+	}
+	return "unknown"
+}
+
+// Value is characteristic value normalized to bool, float64, string or nil.
+// HAP devices send numbers for bools and JSON decoding turns every number
+// into float64, while app writes ints and float32s, so widgets should not
+// type-switch raw values themselves.
+type Value struct {
+	v interface{}
+}
+
+func ValueOf(v interface{}) Value {
+	switch n := v.(type) {
+	case Value:
+		return n
+	case nil, bool, string, float64:
+		return Value{v: n}
+	case float32:
+		// float64(n) would turn 21.3 into 21.299999237060547
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(n), 'g', -1, 32), 64)
+		return Value{v: f}
+	case int:
+		return Value{v: float64(n)}
+	case int8:
+		return Value{v: float64(n)}
+	case int16:
+		return Value{v: float64(n)}
+	case int32:
+		return Value{v: float64(n)}
+	case int64:
+		return Value{v: float64(n)}
+	case uint:
+		return Value{v: float64(n)}
+	case uint8:
+		return Value{v: float64(n)}
+	case uint16:
+		return Value{v: float64(n)}
+	case uint32:
+		return Value{v: float64(n)}
+	case uint64:
+		return Value{v: float64(n)}
+	case *float64:
+		if n == nil {
+			return Value{}
+		}
+		return Value{v: *n}
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return Value{v: f}
+		}
+		return Value{v: n.String()}
+	}
+	return Value{v: v}
+}
+
+// Raw returns normalized value
+func (v Value) Raw() interface{} {
+	return v.v
+}
+
+func (v Value) IsNil() bool {
+	return v.v == nil
+}
+
+// Bool converts value to bool, numbers are true when positive
+func (v Value) Bool() bool {
+	switch n := v.v.(type) {
+	case bool:
+		return n
+	case float64:
+		return n > 0
+	}
+	return false
+}
+
+// Float returns numeric value, bools are 0 and 1
+func (v Value) Float() (float64, bool) {
+	switch n := v.v.(type) {
+	case float64:
+		return n, true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Int is Float for enum-like characteristics
+func (v Value) Int() (int, bool) {
+	f, ok := v.Float()
+	return int(f), ok
+}
+
+// Text returns value of string characteristic
+func (v Value) Text() (string, bool) {
+	s, ok := v.v.(string)
+	return s, ok
+}
+
+func (v Value) String() string {
+	switch n := v.v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v.v)
+}
+
+// ValueEvent is change of characteristic value
+type ValueEvent struct {
+	Key    CharKey
+	Value  Value
+	Source Source
+	// Writer is widget which wrote value, set for SourceWrite and SourceRollback
+	Writer interface{}
+	// Pending is set for optimistic value of write not confirmed yet
+	Pending bool
+	Time    time.Time
+}
+
+// Echo reports whether event is result of write made by w
+func (e ValueEvent) Echo(w interface{}) bool {
+	return e.Source == SourceWrite && w != nil && e.Writer == w
+}
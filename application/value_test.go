@@ -0,0 +1,105 @@
+package application
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValueOf(t *testing.T) {
+	f := 22.5
+	var nilFloat *float64
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{"nil", nil, nil},
+		{"bool", true, true},
+		{"string", "tv", "tv"},
+		{"float64", 21.5, 21.5},
+		{"float32", float32(21.3), 21.3},
+		{"float32 whole", float32(20), 20.0},
+		{"int", 1, 1.0},
+		{"int8", int8(-90), -90.0},
+		{"uint8", uint8(255), 255.0},
+		{"uint64", uint64(3600), 3600.0},
+		{"*float64", &f, 22.5},
+		{"nil *float64", nilFloat, nil},
+		{"json number", json.Number("42"), 42.0},
+		{"json not a number", json.Number("x"), "x"},
+		{"value", ValueOf(float32(0.1)), 0.1},
+		{"unknown", []int{1}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValueOf(tt.in).Raw()
+			if want, ok := tt.want.([]int); ok {
+				if g, ok := got.([]int); !ok || len(g) != len(want) || g[0] != want[0] {
+					t.Errorf("ValueOf(%v) = %#v, want %#v", tt.in, got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ValueOf(%v) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueConversions(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      interface{}
+		bool    bool
+		float   float64
+		floatOk bool
+		str     string
+	}{
+		{"nil", nil, false, 0, false, ""},
+		{"true", true, true, 1, true, "true"},
+		{"false", false, false, 0, true, "false"},
+		{"one", 1, true, 1, true, "1"},
+		{"zero", 0.0, false, 0, true, "0"},
+		{"fraction", float32(21.3), true, 21.3, true, "21.3"},
+		{"string", "on", false, 0, false, "on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := ValueOf(tt.in)
+			if got := v.Bool(); got != tt.bool {
+				t.Errorf("Bool() = %v, want %v", got, tt.bool)
+			}
+			if got, ok := v.Float(); got != tt.float || ok != tt.floatOk {
+				t.Errorf("Float() = %v, %v, want %v, %v", got, ok, tt.float, tt.floatOk)
+			}
+			if got := v.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
+
+func TestValueEventEcho(t *testing.T) {
+	card, other := new(int), new(int)
+	tests := []struct {
+		name string
+		ev   ValueEvent
+		w    interface{}
+		want bool
+	}{
+		{"own write", ValueEvent{Source: SourceWrite, Writer: card}, card, true},
+		{"own pending write", ValueEvent{Source: SourceWrite, Writer: card, Pending: true}, card, true},
+		{"other writer", ValueEvent{Source: SourceWrite, Writer: other}, card, false},
+		{"own rollback", ValueEvent{Source: SourceRollback, Writer: card}, card, false},
+		{"hap event", ValueEvent{Source: SourceHAP}, card, false},
+		{"poll", ValueEvent{Source: SourcePoll}, card, false},
+		{"nil writer", ValueEvent{Source: SourceWrite}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ev.Echo(tt.w); got != tt.want {
+				t.Errorf("Echo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -74,3 +74,8 @@ var ExitIcon *widget.Icon = func() *widget.Icon {
 	icon, _ := widget.NewIcon(icons.ActionExitToApp)
 	return icon
 }()
+
+var BatteryAlertIcon *widget.Icon = func() *widget.Icon {
+	icon, _ := widget.NewIcon(icons.DeviceBatteryAlert)
+	return icon
+}()
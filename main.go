@@ -105,6 +105,7 @@ func main() {
 				go func(d *hkontroller.Device) {
 					for range dev.OnClose() {
 						fmt.Println("dev onclose ", dev.Name)
+						myapp.State.Invalidate(dev)
 						updatePages()
 						w.Invalidate()
 					}
@@ -112,6 +113,7 @@ func main() {
 				go func(d *hkontroller.Device) {
 					for range dev.OnLost() {
 						fmt.Println("dev onlost ", dev.Name)
+						myapp.State.Invalidate(dev)
 						updatePages()
 						w.Invalidate()
 					}
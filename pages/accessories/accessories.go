@@ -8,7 +8,9 @@ import (
 	"hkapp/widgets"
 	"hkapp/widgets/accessory_card"
 	"hkapp/widgets/accessory_page"
+	"hkapp/widgets/service_cards"
 	"image/color"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +33,93 @@ type DeviceAccPair struct {
 	*hkontroller.Accessory
 }
 
+// accKey identifies accessory among devices
+type accKey struct {
+	device string
+	aid    uint64
+}
+
+func (p DeviceAccPair) key() accKey {
+	return accKey{device: p.Device.Name, aid: p.Accessory.Id}
+}
+
+// signature describes services and characteristics of accessory,
+// cards are rebuilt only when it changes. Besides layout it covers
+// everything cards take from description when built: constraints of
+// characteristics and values which are not updated by events, e.g. names.
+func (p DeviceAccPair) signature() string {
+	var sb strings.Builder
+	for _, s := range p.Accessory.Ss {
+		fmt.Fprintf(&sb, "%d:%s:%v:%v:%v[", s.Iid, s.Type, flag(s.Hidden), flag(s.Primary), s.Linked)
+		for _, c := range s.Cs {
+			fmt.Fprintf(&sb, "%d:%s:%s:%v:%s:%v..%v/%v:%v:%d", c.Iid, c.Type, c.Format,
+				c.Permissions, c.Unit, c.MinValue, c.MaxValue, c.MinStep, c.ValidValues, c.MaxLen)
+			if !service_cards.HasPerm(c, service_cards.PermEvents) {
+				fmt.Fprintf(&sb, "=%v", c.Value)
+			}
+			sb.WriteString(",")
+		}
+		sb.WriteString("]")
+	}
+	return sb.String()
+}
+
+func flag(b *bool) bool {
+	return b != nil && *b
+}
+
+// cardDiff tells how cards of old accessories turn into cards of new ones
+type cardDiff struct {
+	// kept[i] is index of old card reused for new accessory i, -1 if card is built anew
+	kept []int
+	// removed are indexes of old cards which are not reused
+	removed []int
+	// resubscribe are reconnected devices and devices with rebuilt cards,
+	// subscriptions they share with other widgets are renewed
+	resubscribe []*hkontroller.Device
+}
+
+// diffCards keeps card of accessory if its device and signature are the same
+func diffCards(old, accs []DeviceAccPair) cardDiff {
+	existing := make(map[accKey]int, len(old))
+	for j, accdev := range old {
+		existing[accdev.key()] = j
+	}
+
+	diff := cardDiff{kept: make([]int, len(accs))}
+	reused := make(map[int]bool, len(old))
+	seen := make(map[*hkontroller.Device]bool)
+	resubscribe := func(d *hkontroller.Device) {
+		if !seen[d] {
+			seen[d] = true
+			diff.resubscribe = append(diff.resubscribe, d)
+		}
+	}
+	for i, accdev := range accs {
+		diff.kept[i] = -1
+		j, ok := existing[accdev.key()]
+		if !ok {
+			continue
+		}
+		if old[j].Device != accdev.Device || old[j].signature() != accdev.signature() {
+			resubscribe(accdev.Device)
+			continue
+		}
+		diff.kept[i] = j
+		reused[j] = true
+		if old[j].Accessory != accdev.Accessory {
+			// accessories were fetched again, i.e. device reconnected
+			resubscribe(accdev.Device)
+		}
+	}
+	for j := range old {
+		if !reused[j] {
+			diff.removed = append(diff.removed, j)
+		}
+	}
+	return diff
+}
+
 // Page holds the state for a page demonstrating the features of
 // the NavDrawer component.
 type Page struct {
@@ -52,8 +141,8 @@ type Page struct {
 	lastSelectedTag  string
 	clearSelectedTag widget.Clickable
 
-	// clickable elements for cards
-	clickables    []widgets.LongClickable
+	// clickable elements for cards, pointers are kept by cards
+	clickables    []*widgets.LongClickable
 	tagClickables []widget.Clickable
 	tagInput      widget.Editor
 	addTagClick   widget.Clickable
@@ -67,6 +156,10 @@ type Page struct {
 
 	showSettings bool
 
+	// show only accessories with low battery
+	lowBatteryOnly      bool
+	lowBatteryFilterBtn widget.Clickable
+
 	// index of selected accessory
 	selectedAccIdx  int
 	selectedAccPage interface {
@@ -110,86 +203,94 @@ func New(app *application.App) *Page {
 
 var _ page.Page = &Page{}
 
+// Update syncs cards with verified devices.
+// Cards of accessories which didn't change are kept with their state and subscriptions,
+// only added accessories get new cards and removed ones are unsubscribed.
 func (p *Page) Update() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	for i := range p.cards {
-		p.cards[i].UnsubscribeFromEvents()
+
+	var selected *accKey
+	if p.selectedAccIdx > -1 && p.selectedAccIdx < len(p.accs) {
+		k := p.accs[p.selectedAccIdx].key()
+		selected = &k
 	}
-	if p.selectedTag == "" {
-		devices := p.App.Manager.GetVerifiedDevices()
 
-		var selectedAcc *DeviceAccPair
-		if p.selectedAccIdx > -1 && p.selectedAccIdx < len(p.accs) {
-			selectedAcc = &p.accs[p.selectedAccIdx]
-		}
+	accs := p.collectAccs()
+	diff := diffCards(p.accs, accs)
 
-		p.accs = []DeviceAccPair{}
-		p.clickables = []widgets.LongClickable{}
-		p.cards = []*accessory_card.AccessoryCard{}
-
-		var openedAccFound bool
-		for _, d := range devices {
-			accs := d.Accessories()
-			for _, a := range accs {
-				p.accs = append(
-					p.accs,
-					DeviceAccPair{
-						Device:    d,
-						Accessory: a,
-					})
-				if selectedAcc != nil {
-					if selectedAcc.Device.Name == d.Name &&
-						selectedAcc.Accessory.Id == a.Id {
-						openedAccFound = true
-					}
-				}
-			}
+	// removed cards let go of their subscriptions first,
+	// so new cards of reconnected device do not share them
+	for _, j := range diff.removed {
+		p.cards[j].UnsubscribeFromEvents()
+	}
+
+	cards := make([]*accessory_card.AccessoryCard, len(accs))
+	clickables := make([]*widgets.LongClickable, len(accs))
+	for i, accdev := range accs {
+		if j := diff.kept[i]; j > -1 {
+			cards[i] = p.cards[j]
+			cards[i].SetAccessory(accdev.Accessory)
+			clickables[i] = p.clickables[j]
+			continue
 		}
-		if !openedAccFound {
-			p.closeSelectedAcc.Click()
+		c := widgets.NewLongClickable(500 * time.Millisecond)
+		clickables[i] = &c
+		cards[i] = accessory_card.NewAccessoryCard(p.App, accdev.Accessory, accdev.Device, clickables[i])
+		cards[i].SubscribeToEvents()
+	}
+	// renewing subscriptions talks to devices, so it runs
+	// in background, neither under p.mu nor on UI goroutine
+	for _, d := range diff.resubscribe {
+		go p.App.State.Resubscribe(d)
+	}
+
+	p.accs = accs
+	p.cards = cards
+	p.clickables = clickables
+
+	// opened accessory may have moved or gone
+	if selected == nil {
+		return
+	}
+	for i, accdev := range p.accs {
+		if accdev.key() == *selected {
+			p.selectedAccIdx = i
+			return
 		}
-	} else {
-		devices := p.App.Manager.GetVerifiedDevices()
-
-		p.accs = []DeviceAccPair{}
-		p.clickables = []widgets.LongClickable{}
-		p.cards = []*accessory_card.AccessoryCard{}
-
-		for _, d := range devices {
-			accs := d.Accessories()
-			for _, a := range accs {
-				meta, err := p.App.Load(d.Name, a.Id)
-				if err == nil {
-					if tt, ok := meta["tags"]; ok {
-						for _, t := range tt {
-							if t == p.selectedTag {
-								p.accs = append(
-									p.accs,
-									DeviceAccPair{
-										Device:    d,
-										Accessory: a,
-									})
-							}
-						}
-					} else {
-						p.selectedAccTags = []string{}
-					}
-				} else {
-					continue
+	}
+	if p.selectedTag == "" {
+		p.closeSelectedAcc.Click()
+	}
+}
+
+// collectAccs lists accessories of verified devices, filtered by selected tag
+func (p *Page) collectAccs() []DeviceAccPair {
+	var accs []DeviceAccPair
+	devices := p.App.Manager.GetVerifiedDevices()
+	for _, d := range devices {
+		for _, a := range d.Accessories() {
+			if p.selectedTag == "" {
+				accs = append(accs, DeviceAccPair{Device: d, Accessory: a})
+				continue
+			}
+			meta, err := p.App.Load(d.Name, a.Id)
+			if err != nil {
+				continue
+			}
+			tt, ok := meta["tags"]
+			if !ok {
+				p.selectedAccTags = []string{}
+				continue
+			}
+			for _, t := range tt {
+				if t == p.selectedTag {
+					accs = append(accs, DeviceAccPair{Device: d, Accessory: a})
 				}
 			}
 		}
 	}
-	p.clickables = make([]widgets.LongClickable, len(p.accs))
-	p.cards = make([]*accessory_card.AccessoryCard, len(p.accs))
-	for i, accdev := range p.accs {
-		a := accdev.Accessory
-		d := accdev.Device
-		p.clickables[i] = widgets.NewLongClickable(500 * time.Millisecond)
-		p.cards[i] = accessory_card.NewAccessoryCard(p.App, a, d, &p.clickables[i])
-		p.cards[i].SubscribeToEvents()
-	}
+	return accs
 }
 
 func (p *Page) Actions() []component.AppBarAction {
@@ -225,12 +326,31 @@ func (p *Page) Actions() []component.AppBarAction {
 			),
 		}
 	} else {
-		{
+		lowBatteryAction := component.AppBarAction{
+			OverflowAction: component.OverflowAction{
+				Name: "Low battery",
+				Tag:  &p.lowBatteryFilterBtn,
+			},
+			Layout: func(gtx layout.Context, bg, fg color.NRGBA) layout.Dimensions {
+				for p.lowBatteryFilterBtn.Clicked() {
+					p.lowBatteryOnly = !p.lowBatteryOnly
+				}
+				btn := component.SimpleIconButton(bg, fg,
+					&p.lowBatteryFilterBtn, icon.BatteryAlertIcon)
+				btn.Background = bg
+				if p.lowBatteryOnly {
+					btn.Color = color.NRGBA{R: 200, A: 128}
+				} else {
+					btn.Color = fg
+				}
+				return btn.Layout(gtx)
+			},
 		}
 		if p.selectedTag == "" {
-			return []component.AppBarAction{}
+			return []component.AppBarAction{lowBatteryAction}
 		} else {
 			return []component.AppBarAction{
+				lowBatteryAction,
 				{
 					OverflowAction: component.OverflowAction{
 						Name: "Clear",
@@ -449,14 +569,27 @@ func (p *Page) Layout(gtx C, th *material.Theme) D {
 
 						p.mu.Lock()
 						defer p.mu.Unlock()
+
+						// indexes of cards passing filter
+						visible := make([]int, 0, len(p.cards))
+						for i, c := range p.cards {
+							if p.lowBatteryOnly && !c.LowBattery() {
+								continue
+							}
+							visible = append(visible, i)
+						}
+						if p.lowBatteryOnly && len(visible) == 0 {
+							return material.Body1(p.th, "no accessories with low battery").Layout(gtx)
+						}
+
 						return listStyle.Layout(gtx, 1, func(gtx C, i int) D {
-							return p.FlowWrap.Layout(gtx, len(p.accs), func(gtx C, i int) D {
-								if i >= len(p.accs) {
+							return p.FlowWrap.Layout(gtx, len(visible), func(gtx C, i int) D {
+								if i >= len(visible) {
 									return D{}
 								}
 
 								var children []layout.Widget
-								w := p.cards[i]
+								w := p.cards[visible[i]]
 								children = append(children, w.Layout)
 
 								var flexChildren []layout.FlexChild
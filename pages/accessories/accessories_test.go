@@ -0,0 +1,93 @@
+package accessories
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hkontrol/hkontroller"
+)
+
+// testAcc makes accessory with lightbulb service named name
+func testAcc(id uint64, name string) *hkontroller.Accessory {
+	return &hkontroller.Accessory{
+		Id: id,
+		Ss: []*hkontroller.ServiceDescription{{
+			Iid:  1,
+			Type: hkontroller.SType_LightBulb,
+			Cs: []*hkontroller.CharacteristicDescription{
+				{Iid: 2, Type: hkontroller.CType_On, Format: "bool",
+					Permissions: []string{"pr", "pw", "ev"}, Value: false},
+				{Iid: 3, Type: hkontroller.CType_Name, Format: "string",
+					Permissions: []string{"pr"}, Value: name},
+			},
+		}},
+	}
+}
+
+func TestDiffCards(t *testing.T) {
+	dev := &hkontroller.Device{Name: "dev"}
+	lamp, desk := testAcc(1, "lamp"), testAcc(2, "desk")
+	old := []DeviceAccPair{{dev, lamp}, {dev, desk}}
+
+	reconnected := &hkontroller.Device{Name: "dev"}
+	other := &hkontroller.Device{Name: "other"}
+	names := map[*hkontroller.Device]string{dev: "dev", reconnected: "reconnected", other: "other"}
+
+	tests := []struct {
+		name        string
+		accs        []DeviceAccPair
+		kept        []int
+		removed     []int
+		resubscribe []string
+	}{
+		{
+			name: "nothing changed",
+			accs: []DeviceAccPair{{dev, lamp}, {dev, desk}},
+			kept: []int{0, 1},
+		},
+		{
+			name:    "accessory of other device added, one removed",
+			accs:    []DeviceAccPair{{other, testAcc(1, "lamp")}, {dev, desk}},
+			kept:    []int{-1, 1},
+			removed: []int{0},
+		},
+		{
+			name:        "accessories fetched again",
+			accs:        []DeviceAccPair{{dev, testAcc(1, "lamp")}, {dev, testAcc(2, "desk")}},
+			kept:        []int{0, 1},
+			resubscribe: []string{"dev"},
+		},
+		{
+			name:        "device reconnected as new object",
+			accs:        []DeviceAccPair{{reconnected, testAcc(1, "lamp")}, {reconnected, testAcc(2, "desk")}},
+			kept:        []int{-1, -1},
+			removed:     []int{0, 1},
+			resubscribe: []string{"reconnected"},
+		},
+		{
+			name:        "accessory renamed",
+			accs:        []DeviceAccPair{{dev, lamp}, {dev, testAcc(2, "table")}},
+			kept:        []int{0, -1},
+			removed:     []int{1},
+			resubscribe: []string{"dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffCards(old, tt.accs)
+			var resubscribe []string
+			for _, d := range diff.resubscribe {
+				resubscribe = append(resubscribe, names[d])
+			}
+			if fmt.Sprint(diff.kept) != fmt.Sprint(tt.kept) {
+				t.Errorf("kept %v, want %v", diff.kept, tt.kept)
+			}
+			if fmt.Sprint(diff.removed) != fmt.Sprint(tt.removed) {
+				t.Errorf("removed %v, want %v", diff.removed, tt.removed)
+			}
+			if fmt.Sprint(resubscribe) != fmt.Sprint(tt.resubscribe) {
+				t.Errorf("resubscribed %v, want %v", resubscribe, tt.resubscribe)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package pages
+
+import (
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Banner is an in-app alert shown above page content until dismissed
+type Banner struct {
+	Tag  interface{}
+	Text string
+
+	dismiss widget.Clickable
+}
+
+var bannerBg = color.NRGBA{R: 200, G: 30, B: 30, A: 255}
+
+// ShowBanner shows banner identified by tag.
+// If banner with the same tag is already shown, only its text is updated.
+// Safe to call from any goroutine.
+func (r *Router) ShowBanner(tag interface{}, text string) {
+	r.bannersMu.Lock()
+	defer r.bannersMu.Unlock()
+	for _, b := range r.banners {
+		if b.Tag == tag {
+			b.Text = text
+			return
+		}
+	}
+	r.banners = append(r.banners, &Banner{Tag: tag, Text: text})
+}
+
+// DismissBanner removes banner identified by tag
+func (r *Router) DismissBanner(tag interface{}) {
+	r.bannersMu.Lock()
+	defer r.bannersMu.Unlock()
+	for i, b := range r.banners {
+		if b.Tag == tag {
+			r.banners = append(r.banners[:i], r.banners[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *Router) layoutBanners(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	// ShowBanner may change text from another goroutine, so it is copied under lock
+	r.bannersMu.Lock()
+	banners := make([]*Banner, len(r.banners))
+	copy(banners, r.banners)
+	texts := make([]string, len(r.banners))
+	for i, b := range r.banners {
+		texts[i] = b.Text
+	}
+	r.bannersMu.Unlock()
+
+	var dismissed []interface{}
+	children := make([]layout.FlexChild, 0, len(banners))
+	for i, b := range banners {
+		b, text := b, texts[i]
+		for b.dismiss.Clicked() {
+			dismissed = append(dismissed, b.Tag)
+		}
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return b.layout(gtx, th, text)
+		}))
+	}
+	for _, tag := range dismissed {
+		r.DismissBanner(tag)
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+func (b *Banner) layout(gtx layout.Context, th *material.Theme, text string) layout.Dimensions {
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+			defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+			paint.Fill(gtx.Ops, bannerBg)
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						lbl := material.Body1(th, text)
+						lbl.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						btn := material.Button(th, &b.dismiss, "Dismiss")
+						btn.Background = color.NRGBA{A: 64}
+						return btn.Layout(gtx)
+					}),
+				)
+			})
+		}),
+	)
+}
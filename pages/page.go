@@ -2,6 +2,7 @@ package pages
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"hkapp/icon"
@@ -27,6 +28,12 @@ type Router struct {
 	*component.AppBar
 	*component.ModalLayer
 	NonModalDrawer, BottomBar bool
+
+	bannersMu sync.Mutex
+	banners   []*Banner
+
+	snackbarMu sync.Mutex
+	snackbar   *Snackbar
 }
 
 func NewRouter() *Router {
@@ -95,24 +102,35 @@ func (r *Router) Layout(gtx layout.Context, th *material.Theme) layout.Dimension
 	}
 	paint.Fill(gtx.Ops, th.Palette.Bg)
 	content := layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-		return layout.Flex{}.Layout(gtx,
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				gtx.Constraints.Max.X /= 3
-				return r.NavDrawer.Layout(gtx, th, &r.NavAnim)
+		return layout.Stack{Alignment: layout.S}.Layout(gtx,
+			layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+				gtx.Constraints.Min = gtx.Constraints.Max
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints.Max.X /= 3
+						return r.NavDrawer.Layout(gtx, th, &r.NavAnim)
+					}),
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return r.pages[r.current].Layout(gtx, th)
+					}),
+				)
 			}),
-			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-				return r.pages[r.current].Layout(gtx, th)
+			layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+				return r.layoutSnackbar(gtx, th)
 			}),
 		)
 	})
 	bar := layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 		return r.AppBar.Layout(gtx, th, "Menu", "Actions")
 	})
+	banners := layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+		return r.layoutBanners(gtx, th)
+	})
 	flex := layout.Flex{Axis: layout.Vertical}
 	if r.BottomBar {
-		flex.Layout(gtx, content, bar)
+		flex.Layout(gtx, banners, content, bar)
 	} else {
-		flex.Layout(gtx, bar, content)
+		flex.Layout(gtx, bar, banners, content)
 	}
 	r.ModalLayer.Layout(gtx, th)
 	return layout.Dimensions{Size: gtx.Constraints.Max}
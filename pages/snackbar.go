@@ -0,0 +1,113 @@
+package pages
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const snackbarDuration = 6 * time.Second
+
+// Snackbar is short message shown at the bottom of window for a while,
+// optionally with action button
+type Snackbar struct {
+	Text     string
+	Action   string
+	OnAction func()
+
+	expires time.Time
+	action  widget.Clickable
+}
+
+var snackbarBg = color.NRGBA{R: 50, G: 50, B: 50, A: 240}
+
+// ShowSnackbar shows message replacing the previous one.
+// If action is not empty, button with this label calls onAction.
+// Safe to call from any goroutine.
+func (r *Router) ShowSnackbar(text string, action string, onAction func()) {
+	r.snackbarMu.Lock()
+	defer r.snackbarMu.Unlock()
+	r.snackbar = &Snackbar{
+		Text:     text,
+		Action:   action,
+		OnAction: onAction,
+		expires:  time.Now().Add(snackbarDuration),
+	}
+}
+
+func (r *Router) layoutSnackbar(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	r.snackbarMu.Lock()
+	s := r.snackbar
+	if s != nil && !gtx.Now.Before(s.expires) {
+		r.snackbar = nil
+		s = nil
+	}
+	r.snackbarMu.Unlock()
+	if s == nil {
+		return layout.Dimensions{}
+	}
+
+	clicked := false
+	for s.action.Clicked() {
+		clicked = true
+	}
+	if clicked {
+		r.snackbarMu.Lock()
+		if r.snackbar == s {
+			r.snackbar = nil
+		}
+		r.snackbarMu.Unlock()
+		if s.OnAction != nil {
+			s.OnAction()
+		}
+		return layout.Dimensions{}
+	}
+
+	// redraw to hide snackbar when it expires
+	op.InvalidateOp{At: s.expires}.Add(gtx.Ops)
+
+	return layout.UniformInset(unit.Dp(16)).Layout(gtx, s.layout(th))
+}
+
+func (s *Snackbar) layout(th *material.Theme) layout.Widget {
+	return func(gtx layout.Context) layout.Dimensions {
+		return layout.Stack{}.Layout(gtx,
+			layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+				rr := gtx.Dp(unit.Dp(4))
+				defer clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Push(gtx.Ops).Pop()
+				paint.Fill(gtx.Ops, snackbarBg)
+				return layout.Dimensions{Size: gtx.Constraints.Min}
+			}),
+			layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+				return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					children := []layout.FlexChild{
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							lbl := material.Body1(th, s.Text)
+							lbl.Color = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+							return lbl.Layout(gtx)
+						}),
+					}
+					if s.Action != "" {
+						children = append(children,
+							layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								btn := material.Button(th, &s.action, s.Action)
+								btn.Background = color.NRGBA{A: 0}
+								btn.Color = th.Palette.ContrastBg
+								return btn.Layout(gtx)
+							}))
+					}
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+				})
+			}),
+		)
+	}
+}
@@ -29,6 +29,9 @@ type AccessoryCard struct {
 	primary       *hkontroller.ServiceDescription
 	primaryWidget interface{ Layout(C) D }
 
+	// compact badge for BatteryService, if any
+	battery *service_cards.Battery
+
 	*application.App
 }
 
@@ -45,28 +48,40 @@ func NewAccessoryCard(app *application.App, acc *hkontroller.Accessory, dev *hko
 		}
 	}
 	// primary service was not selected
-	// then we select first, but not accessory info
+	// then we select first, but not accessory info or battery
 	if primary == nil {
 		for _, srv := range acc.Ss {
-			if srv.Type == hkontroller.SType_AccessoryInfo {
+			if srv.Type == hkontroller.SType_AccessoryInfo ||
+				srv.Type == hkontroller.SType_BatteryService {
 				continue
 			}
 			primary = srv
 			break
 		}
 	}
+	// accessory with battery only
+	if primary == nil {
+		primary = acc.GetService(hkontroller.SType_BatteryService)
+	}
 	var primaryWidget interface{ Layout(C) D }
 	if primary != nil {
-		// TODO: GetQuickWidgetForService
-		// 		  so widgets for full version and quick version differs
-		w, err := service_cards.GetWidgetForService(app, acc, dev, primary, true)
+		w, err := service_cards.GetQuickWidgetForService(app, acc, dev, primary)
 		if err == nil {
 			primaryWidget = w
 		}
 	}
 
+	var battery *service_cards.Battery
+	if bs := acc.GetService(hkontroller.SType_BatteryService); bs != nil && bs != primary {
+		b, err := service_cards.NewBattery(app, acc, dev, bs, true)
+		if err == nil {
+			battery = b
+		}
+	}
+
 	return &AccessoryCard{
 		clickable:     clickable,
+		battery:       battery,
 		acc:           acc,
 		dev:           dev,
 		primary:       primary,
@@ -76,6 +91,12 @@ func NewAccessoryCard(app *application.App, acc *hkontroller.Accessory, dev *hko
 	}
 }
 
+// SetAccessory replaces description of kept card's accessory with fetched again one.
+// It must not differ in services and characteristics, service widgets keep theirs.
+func (s *AccessoryCard) SetAccessory(acc *hkontroller.Accessory) {
+	s.acc = acc
+}
+
 func (s *AccessoryCard) Layout(gtx C) D {
 	label := "---"
 	info := s.acc.GetService(hkontroller.SType_AccessoryInfo)
@@ -89,7 +110,13 @@ func (s *AccessoryCard) Layout(gtx C) D {
 	var cardWidgets []layout.FlexChild
 	cardWidgets = append(cardWidgets,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return material.Body1(s.th, label).Layout(gtx)
+			if s.battery == nil {
+				return material.Body1(s.th, label).Layout(gtx)
+			}
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(material.Body1(s.th, label).Layout),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(6)}.Layout),
+				layout.Rigid(s.battery.Layout))
 		}),
 	)
 
@@ -109,17 +136,23 @@ func (s *AccessoryCard) Layout(gtx C) D {
 		cardWidgets = append(cardWidgets, layout.Rigid(s.primaryWidget.Layout))
 	}
 
+	border := widget.Border{
+		Color: color.NRGBA{
+			R: 0,
+			G: 0,
+			B: 0,
+			A: 64,
+		},
+		Width:        unit.Dp(1),
+		CornerRadius: unit.Dp(3),
+	}
+	if s.Alert() {
+		border.Color = color.NRGBA{R: 200, A: 255}
+		border.Width = unit.Dp(3)
+	}
+
 	content := func(gtx C) D {
-		return widget.Border{
-			Color: color.NRGBA{
-				R: 0,
-				G: 0,
-				B: 0,
-				A: 64,
-			},
-			Width:        unit.Dp(1),
-			CornerRadius: unit.Dp(3),
-		}.Layout(gtx, func(gtx C) D {
+		return border.Layout(gtx, func(gtx C) D {
 			return layout.Inset{
 				Top:    8,
 				Bottom: 8,
@@ -147,6 +180,9 @@ func (s *AccessoryCard) SubscribeToEvents() {
 	if pp, ok := s.primaryWidget.(evented); ok {
 		pp.SubscribeToEvents()
 	}
+	if s.battery != nil {
+		s.battery.SubscribeToEvents()
+	}
 }
 func (s *AccessoryCard) UnsubscribeFromEvents() {
 	type evented interface {
@@ -156,6 +192,27 @@ func (s *AccessoryCard) UnsubscribeFromEvents() {
 	if pp, ok := s.primaryWidget.(evented); ok {
 		pp.UnsubscribeFromEvents()
 	}
+	if s.battery != nil {
+		s.battery.UnsubscribeFromEvents()
+	}
+}
+
+// Alert reports whether primary service is in alarming state, e.g. sensor detected something
+func (s *AccessoryCard) Alert() bool {
+	type withAlert interface {
+		Alert() bool
+	}
+	a, ok := s.primaryWidget.(withAlert)
+	return ok && a.Alert()
+}
+
+// LowBattery reports whether accessory has battery service in low state
+func (s *AccessoryCard) LowBattery() bool {
+	if s.battery != nil {
+		return s.battery.LowBattery()
+	}
+	b, ok := s.primaryWidget.(*service_cards.Battery)
+	return ok && b.LowBattery()
 }
 
 func (s *AccessoryCard) QuickActionSupported() bool {
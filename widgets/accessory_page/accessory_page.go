@@ -1,6 +1,7 @@
 package accessory_page
 
 import (
+	"hkapp/applayout"
 	"hkapp/application"
 	"hkapp/widgets/service_cards"
 
@@ -20,6 +21,11 @@ type evented interface {
 	UnsubscribeFromEvents()
 }
 
+// withLinked is implemented by cards that render their linked services
+type withLinked interface {
+	LinkedServices() []*hkontroller.ServiceDescription
+}
+
 type AccessoryPage struct {
 	acc *hkontroller.Accessory
 	dev *hkontroller.Device
@@ -32,6 +38,10 @@ type AccessoryPage struct {
 		Layout(C) D
 	}
 
+	// developer view with raw characteristics
+	showInspector widget.Bool
+	inspector     *Inspector
+
 	*application.App
 }
 
@@ -49,9 +59,34 @@ func NewAccessoryPage(app *application.App, acc *hkontroller.Accessory, dev *hko
 			Layout(C) D
 		}, 0, len(acc.Ss))
 
+	widgets := make(map[uint64]interface {
+		Layout(C) D
+	})
+	// services already displayed as part of another card
+	linked := make(map[uint64]bool)
+	// cards rendering linked services are built first,
+	// so linked ones are never built, subscribed and fetched on their own
+	for _, linkable := range []bool{false, true} {
+		for _, s := range acc.Ss {
+			if service_cards.IsLinkable(s.Type) != linkable || linked[s.Iid] {
+				continue
+			}
+			w, err := service_cards.GetFullWidgetForService(app, acc, dev, s)
+			if err != nil {
+				continue
+			}
+			widgets[s.Iid] = w
+			if lw, ok := w.(withLinked); ok {
+				for _, ls := range lw.LinkedServices() {
+					linked[ls.Iid] = true
+				}
+			}
+		}
+	}
+	// keep order of services
 	for _, s := range acc.Ss {
-		w, err := service_cards.GetWidgetForService(app, acc, dev, s, false)
-		if err != nil {
+		w, ok := widgets[s.Iid]
+		if !ok || linked[s.Iid] {
 			continue
 		}
 		ap.srvwidgets = append(ap.srvwidgets, w)
@@ -61,11 +96,34 @@ func NewAccessoryPage(app *application.App, acc *hkontroller.Accessory, dev *hko
 }
 
 func (p *AccessoryPage) Layout(gtx C) D {
+	if p.showInspector.Changed() {
+		if p.showInspector.Value {
+			p.inspector = NewInspector(p.App, p.acc, p.dev)
+		} else if p.inspector != nil {
+			p.inspector.UnsubscribeFromEvents()
+			p.inspector = nil
+		}
+	}
+
+	var content []layout.Widget
+	content = append(content, func(gtx C) D {
+		return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+			material.Body2(p.th, "Developer view").Layout,
+			material.Switch(p.th, &p.showInspector, "Developer view").Layout)
+	})
+	if p.inspector != nil {
+		content = append(content, p.inspector.Layout)
+	} else {
+		for _, w := range p.srvwidgets {
+			content = append(content, w.Layout)
+		}
+	}
+
 	// here we loop through all the events associated with this button.
 	p.List.Axis = layout.Vertical
 	listStyle := material.List(p.th, &p.List)
-	return listStyle.Layout(gtx, len(p.srvwidgets), func(gtx C, i int) D {
-		return p.srvwidgets[i].Layout(gtx)
+	return listStyle.Layout(gtx, len(content), func(gtx C, i int) D {
+		return content[i](gtx)
 	})
 }
 
@@ -83,4 +141,7 @@ func (p *AccessoryPage) UnsubscribeFromEvents() {
 			pp.UnsubscribeFromEvents()
 		}
 	}
+	if p.inspector != nil {
+		p.inspector.UnsubscribeFromEvents()
+	}
 }
@@ -0,0 +1,384 @@
+package accessory_page
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hkapp/application"
+	"hkapp/widgets/service_cards"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+var inspectorErrColor = color.NRGBA{R: 200, A: 255}
+
+// inspectorChar is state of single characteristic in inspector
+type inspectorChar struct {
+	cc *hkontroller.CharacteristicDescription
+
+	readBtn  widget.Clickable
+	input    widget.Editor
+	writeBtn widget.Clickable
+	events   widget.Bool
+
+	// guarded by Inspector.mu
+	value  application.Value
+	status string
+	err    string
+
+	// value is watched in store while inspector is shown
+	watch application.WatchID
+}
+
+type inspectorService struct {
+	srv   *hkontroller.ServiceDescription
+	chars []*inspectorChar
+}
+
+// Inspector is developer view listing raw services and characteristics of accessory.
+// It allows to read and write any characteristic and to toggle event notifications.
+type Inspector struct {
+	acc *hkontroller.Accessory
+	dev *hkontroller.Device
+
+	services []*inspectorService
+
+	mu sync.Mutex
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewInspector(app *application.App, acc *hkontroller.Accessory, dev *hkontroller.Device) *Inspector {
+	in := &Inspector{
+		acc: acc,
+		dev: dev,
+		th:  app.Theme,
+		App: app,
+	}
+	for _, s := range acc.Ss {
+		is := &inspectorService{srv: s}
+		for _, cc := range s.Cs {
+			ic := &inspectorChar{
+				cc:    cc,
+				value: application.ValueOf(cc.Value),
+				input: widget.Editor{SingleLine: true, Submit: true},
+			}
+			in.watch(ic, false)
+			is.chars = append(is.chars, ic)
+		}
+		in.services = append(in.services, is)
+	}
+	return in
+}
+
+func (in *Inspector) read(ic *inspectorChar) {
+	go func() {
+		// watcher shows value read
+		_, err := in.App.State.Fetch(in.dev, in.acc.Id, ic.cc.Iid)
+		in.mu.Lock()
+		if err != nil {
+			ic.err = err.Error()
+		} else {
+			ic.err = ""
+		}
+		in.mu.Unlock()
+		in.App.Window.Invalidate()
+	}()
+}
+
+func (in *Inspector) write(ic *inspectorChar) {
+	value, err := parseCharValue(ic.cc, ic.input.Text())
+	if err != nil {
+		in.mu.Lock()
+		ic.err = err.Error()
+		in.mu.Unlock()
+		return
+	}
+	in.mu.Lock()
+	ic.err = ""
+	in.mu.Unlock()
+	if !service_cards.HasPerm(ic.cc, service_cards.PermRead) {
+		in.App.State.MarkWriteOnly(in.dev, in.acc.Id, ic.cc.Iid)
+	}
+	// written like from any card: watcher shows pending value and its outcome,
+	// snackbar explains failure
+	in.App.WriteValue(in.dev, in.acc.Id, ic.cc.Iid, value, in,
+		fmt.Sprintf("%d.%d %s", in.acc.Id, ic.cc.Iid, ic.cc.Type.String()))
+}
+
+// watch follows value of characteristic in store, with HAP events if events is set
+func (in *Inspector) watch(ic *inspectorChar, events bool) {
+	id := in.App.State.Watch(in.dev, in.acc.Id, ic.cc.Iid, events,
+		func(ev application.ValueEvent) {
+			in.mu.Lock()
+			ic.value = ev.Value
+			ic.status = ev.Source.String() + " " + ev.Time.Format("15:04:05")
+			if ev.Pending {
+				ic.status += ", pending"
+			}
+			if ev.Source == application.SourceRollback && ev.Writer == in {
+				ic.err = "write failed, previous value restored"
+			}
+			in.mu.Unlock()
+		})
+	in.mu.Lock()
+	ic.watch = id
+	in.mu.Unlock()
+}
+
+func (in *Inspector) unwatch(ic *inspectorChar) {
+	in.mu.Lock()
+	id := ic.watch
+	in.mu.Unlock()
+	in.App.State.Unwatch(id)
+}
+
+// UnsubscribeFromEvents removes all watches made in inspector
+func (in *Inspector) UnsubscribeFromEvents() {
+	for _, is := range in.services {
+		for _, ic := range is.chars {
+			in.unwatch(ic)
+			ic.events.Value = false
+		}
+	}
+}
+
+func (in *Inspector) update() {
+	for _, is := range in.services {
+		for _, ic := range is.chars {
+			for ic.readBtn.Clicked() {
+				in.read(ic)
+			}
+			for ic.writeBtn.Clicked() {
+				in.write(ic)
+			}
+			for _, e := range ic.input.Events() {
+				if _, ok := e.(widget.SubmitEvent); ok {
+					in.write(ic)
+				}
+			}
+			if ic.events.Changed() {
+				in.unwatch(ic)
+				in.watch(ic, ic.events.Value)
+			}
+		}
+	}
+}
+
+func (in *Inspector) Layout(gtx C) D {
+	in.update()
+
+	var children []layout.FlexChild
+	for _, is := range in.services {
+		is := is
+		children = append(children,
+			layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+			layout.Rigid(material.Body1(in.th,
+				fmt.Sprintf("Service %s [%s] iid %d", is.srv.Type.String(), string(is.srv.Type), is.srv.Iid)).Layout))
+		for _, ic := range is.chars {
+			ic := ic
+			children = append(children, layout.Rigid(func(gtx C) D {
+				return layout.Inset{Left: unit.Dp(12), Bottom: unit.Dp(6)}.Layout(gtx, func(gtx C) D {
+					return in.layoutChar(gtx, ic)
+				})
+			}))
+		}
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+func (in *Inspector) layoutChar(gtx C, ic *inspectorChar) D {
+	cc := ic.cc
+
+	in.mu.Lock()
+	valStr := ic.value.String()
+	if ic.value.IsNil() {
+		valStr = "null"
+	}
+	if ic.status != "" {
+		valStr += " (" + ic.status + ")"
+	}
+	errStr := ic.err
+	in.mu.Unlock()
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(material.Body2(in.th,
+			fmt.Sprintf("%d.%d %s [%s]", in.acc.Id, cc.Iid, cc.Type.String(), string(cc.Type))).Layout),
+		layout.Rigid(material.Caption(in.th, charConstraints(cc)).Layout),
+		layout.Rigid(func(gtx C) D {
+			row := []layout.FlexChild{
+				layout.Flexed(1, material.Body2(in.th, "value: "+valStr).Layout),
+			}
+			// reading event characteristic would be taken for new event
+			if service_cards.HasPerm(cc, service_cards.PermRead) && !service_cards.EventOnly(cc) {
+				row = append(row,
+					layout.Rigid(material.Button(in.th, &ic.readBtn, "Read").Layout))
+			}
+			if service_cards.HasPerm(cc, service_cards.PermEvents) {
+				row = append(row,
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Body2(in.th, "events ").Layout),
+					layout.Rigid(material.Switch(in.th, &ic.events, "events").Layout))
+			}
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, row...)
+		}))
+	if service_cards.HasPerm(cc, service_cards.PermWrite) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, material.Editor(in.th, &ic.input, "new value ("+cc.Format+")").Layout),
+					layout.Rigid(material.Button(in.th, &ic.writeBtn, "Write").Layout))
+			}))
+	}
+	if errStr != "" {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Caption(in.th, errStr)
+				lbl.Color = inspectorErrColor
+				return lbl.Layout(gtx)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+
+func charConstraints(cc *hkontroller.CharacteristicDescription) string {
+	parts := []string{
+		"format: " + cc.Format,
+		"perms: " + strings.Join(cc.Permissions, ","),
+	}
+	if cc.Unit != "" {
+		parts = append(parts, "unit: "+cc.Unit)
+	}
+	if cc.MinValue != nil || cc.MaxValue != nil {
+		parts = append(parts, fmt.Sprintf("range: %v..%v", cc.MinValue, cc.MaxValue))
+	}
+	if cc.MinStep != nil {
+		parts = append(parts, fmt.Sprintf("step: %v", cc.MinStep))
+	}
+	if len(cc.ValidValues) > 0 {
+		parts = append(parts, fmt.Sprintf("valid: %v", cc.ValidValues))
+	}
+	if cc.MaxLen > 0 {
+		parts = append(parts, fmt.Sprintf("max len: %d", cc.MaxLen))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// intLimits are value ranges of HAP integer formats
+var intLimits = map[string][2]float64{
+	"uint8":  {0, math.MaxUint8},
+	"uint16": {0, math.MaxUint16},
+	"uint32": {0, math.MaxUint32},
+	"int":    {math.MinInt32, math.MaxInt32},
+}
+
+// parseCharValue converts text input to value of characteristic format
+// and checks it against characteristic constraints
+func parseCharValue(cc *hkontroller.CharacteristicDescription, text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	switch cc.Format {
+	case "bool":
+		switch strings.ToLower(text) {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+		return nil, errors.New("expected true/false or 1/0")
+	case "string":
+		if cc.MaxLen > 0 && len(text) > cc.MaxLen {
+			return nil, fmt.Errorf("value is longer than %d", cc.MaxLen)
+		}
+		return text, nil
+	case "tlv8", "data":
+		// sent as base64 string
+		if _, err := base64.StdEncoding.DecodeString(text); err != nil {
+			return nil, errors.New("expected base64")
+		}
+		return text, nil
+	case "float":
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, errors.New("expected number")
+		}
+		if err := checkRange(cc, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if cc.Format == "uint64" {
+		// does not fit into int64 nor float64
+		v, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, errors.New("expected unsigned integer")
+		}
+		if err := checkRange(cc, float64(v)); err != nil {
+			return nil, err
+		}
+		if err := checkValid(cc, float64(v)); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	limits, ok := intLimits[cc.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q", cc.Format)
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return nil, errors.New("expected integer")
+	}
+	if float64(v) < limits[0] || float64(v) > limits[1] {
+		return nil, fmt.Errorf("value out of %s range", cc.Format)
+	}
+	if err := checkRange(cc, float64(v)); err != nil {
+		return nil, err
+	}
+	if err := checkValid(cc, float64(v)); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func checkRange(cc *hkontroller.CharacteristicDescription, v float64) error {
+	if min, ok := toFloat(cc.MinValue); ok && v < min {
+		return fmt.Errorf("value is less than %v", min)
+	}
+	if max, ok := toFloat(cc.MaxValue); ok && v > max {
+		return fmt.Errorf("value is greater than %v", max)
+	}
+	return nil
+}
+
+func checkValid(cc *hkontroller.CharacteristicDescription, v float64) error {
+	if len(cc.ValidValues) == 0 {
+		return nil
+	}
+	for _, vv := range cc.ValidValues {
+		if float64(vv) == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("value is not one of %v", cc.ValidValues)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	return application.ValueOf(v).Float()
+}
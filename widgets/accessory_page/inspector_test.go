@@ -0,0 +1,53 @@
+package accessory_page
+
+import (
+	"testing"
+
+	"github.com/hkontrol/hkontroller"
+)
+
+func TestParseCharValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		cc      hkontroller.CharacteristicDescription
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{"bool true", hkontroller.CharacteristicDescription{Format: "bool"}, "true", true, false},
+		{"bool 0", hkontroller.CharacteristicDescription{Format: "bool"}, " 0 ", false, false},
+		{"bool invalid", hkontroller.CharacteristicDescription{Format: "bool"}, "on", nil, true},
+		{"string", hkontroller.CharacteristicDescription{Format: "string"}, "Lamp", "Lamp", false},
+		{"string too long", hkontroller.CharacteristicDescription{Format: "string", MaxLen: 3}, "Lamp", nil, true},
+		{"data base64", hkontroller.CharacteristicDescription{Format: "data"}, "AQID", "AQID", false},
+		{"data invalid", hkontroller.CharacteristicDescription{Format: "data"}, "not base64!", nil, true},
+		{"tlv8 base64", hkontroller.CharacteristicDescription{Format: "tlv8"}, "AQEA", "AQEA", false},
+		{"tlv8 bad padding", hkontroller.CharacteristicDescription{Format: "tlv8"}, "AQE", nil, true},
+		{"float", hkontroller.CharacteristicDescription{Format: "float"}, "21.5", 21.5, false},
+		{"float below min", hkontroller.CharacteristicDescription{Format: "float", MinValue: 10.0}, "9.5", nil, true},
+		{"float above max", hkontroller.CharacteristicDescription{Format: "float", MaxValue: 38}, "38.5", nil, true},
+		{"float not number", hkontroller.CharacteristicDescription{Format: "float"}, "warm", nil, true},
+		{"uint8", hkontroller.CharacteristicDescription{Format: "uint8"}, "255", int64(255), false},
+		{"uint8 overflow", hkontroller.CharacteristicDescription{Format: "uint8"}, "256", nil, true},
+		{"uint8 negative", hkontroller.CharacteristicDescription{Format: "uint8"}, "-1", nil, true},
+		{"int negative", hkontroller.CharacteristicDescription{Format: "int"}, "-90", int64(-90), false},
+		{"uint8 valid value", hkontroller.CharacteristicDescription{Format: "uint8", ValidValues: []int{0, 1, 3}}, "3", int64(3), false},
+		{"uint8 not valid value", hkontroller.CharacteristicDescription{Format: "uint8", ValidValues: []int{0, 1, 3}}, "2", nil, true},
+		{"uint64 max", hkontroller.CharacteristicDescription{Format: "uint64"}, "18446744073709551615", uint64(18446744073709551615), false},
+		{"uint64 negative", hkontroller.CharacteristicDescription{Format: "uint64"}, "-1", nil, true},
+		{"uint64 above max", hkontroller.CharacteristicDescription{Format: "uint64", MaxValue: 3600}, "3601", nil, true},
+		{"integer not number", hkontroller.CharacteristicDescription{Format: "uint16"}, "1.5", nil, true},
+		{"unsupported format", hkontroller.CharacteristicDescription{Format: "array"}, "1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCharValue(&tt.cc, tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCharValue(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCharValue(%q) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
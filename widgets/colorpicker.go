@@ -0,0 +1,159 @@
+package widgets
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+// HSPicker is a hue/saturation plane: hue changes along x axis (0-360),
+// saturation along y axis (100 at the top, 0 at the bottom).
+type HSPicker struct {
+	Hue        float32
+	Saturation float32
+
+	changed bool
+	drag    bool
+
+	// cached rendering of the plane
+	size  image.Point
+	plane paint.ImageOp
+}
+
+// Changed reports whether value was changed by user since last call
+func (p *HSPicker) Changed() bool {
+	c := p.changed
+	p.changed = false
+	return c
+}
+
+func (p *HSPicker) update(gtx C, size image.Point) {
+	for _, e := range gtx.Events(p) {
+		ev, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch ev.Type {
+		case pointer.Press:
+			p.drag = true
+		case pointer.Release, pointer.Cancel:
+			p.drag = false
+			continue
+		case pointer.Drag:
+			if !p.drag {
+				continue
+			}
+		default:
+			continue
+		}
+		x := clamp01(ev.Position.X / float32(size.X))
+		y := clamp01(ev.Position.Y / float32(size.Y))
+		p.Hue = x * 360
+		p.Saturation = (1 - y) * 100
+		p.changed = true
+	}
+}
+
+// Layout lays out picker filling available width with given height
+func (p *HSPicker) Layout(gtx C, height unit.Dp) D {
+	size := image.Point{X: gtx.Constraints.Max.X, Y: gtx.Dp(height)}
+	if size.X <= 0 || size.Y <= 0 {
+		return D{}
+	}
+	p.update(gtx, size)
+
+	if size != p.size {
+		p.size = size
+		p.plane = paint.NewImageOp(hsPlane(size))
+	}
+
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	pointer.InputOp{
+		Tag:   p,
+		Types: pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel,
+		Grab:  p.drag,
+	}.Add(gtx.Ops)
+
+	p.plane.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+
+	// marker of current value
+	r := float32(gtx.Dp(6))
+	center := f32.Point{
+		X: clamp01(p.Hue/360) * float32(size.X),
+		Y: (1 - clamp01(p.Saturation/100)) * float32(size.Y),
+	}
+	marker := clip.Ellipse{
+		Min: image.Pt(int(center.X-r), int(center.Y-r)),
+		Max: image.Pt(int(center.X+r), int(center.Y+r)),
+	}
+	paint.FillShape(gtx.Ops, color.NRGBA{A: 255},
+		clip.Stroke{Path: marker.Path(gtx.Ops), Width: float32(gtx.Dp(2))}.Op())
+
+	return D{Size: size}
+}
+
+func hsPlane(size image.Point) *image.RGBA {
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	for x := 0; x < size.X; x++ {
+		h := float32(x) / float32(size.X) * 360
+		for y := 0; y < size.Y; y++ {
+			s := 1 - float32(y)/float32(size.Y)
+			c := HSVColor(h, s*100, 100)
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// HSVColor converts hue (0-360), saturation (0-100) and value (0-100) to color
+func HSVColor(h, s, v float32) color.NRGBA {
+	s = clamp01(s / 100)
+	v = clamp01(v / 100)
+	h = float32(math.Mod(float64(h), 360))
+	if h < 0 {
+		h += 360
+	}
+
+	c := v * s
+	x := c * (1 - float32(math.Abs(math.Mod(float64(h/60), 2)-1)))
+	m := v - c
+
+	var r, g, b float32
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.NRGBA{
+		R: uint8((r + m) * 255),
+		G: uint8((g + m) * 255),
+		B: uint8((b + m) * 255),
+		A: 255,
+	}
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
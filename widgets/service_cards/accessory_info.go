@@ -11,7 +11,6 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 )
 
 type AccessoryInfo struct {
@@ -29,8 +28,6 @@ type AccessoryInfo struct {
 	dev *hkontroller.Device
 	th  *material.Theme
 
-	events <-chan emitter.Event
-
 	*application.App
 }
 
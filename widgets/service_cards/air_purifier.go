@@ -0,0 +1,251 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Air Purifier
+Required Characteristics
+9.1 Active
+9.25 Current Air Purifier State : 0-Inactive 1-Idle 2-Purifying Air
+9.26 Target Air Purifier State : 0-Manual 1-Auto
+
+Optional Characteristics
+9.81 Rotation Speed
+9.99 Swing Mode
+9.51 Lock Physical Controls
+
+Filter Maintenance (linked)
+Required Characteristics
+9.39 Filter Change Indication : 0-Filter OK 1-Change Filter
+
+Optional Characteristics
+9.40 Filter Life Level : 0-100
+9.79 Reset Filter Indication : write 1
+*/
+
+type AirPurifier struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+	// linked FilterMaintenance service, may be nil
+	filter *serviceChars
+
+	active          widget.Bool
+	quickActive     widget.Bool
+	targetStateEnum widget.Enum
+	speedWidget     widget.Float
+	resetFilterBtn  widget.Clickable
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewAirPurifier(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*AirPurifier, error) {
+	a := &AirPurifier{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	a.serviceChars.onValue = a.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	a.label = label
+
+	err = a.require(
+		hkontroller.CType_Active,
+		hkontroller.CType_CurrentAirPurifierState,
+		hkontroller.CType_TargetAirPurifierState)
+	if err != nil {
+		return nil, err
+	}
+	a.optional(hkontroller.CType_RotationSpeed)
+	a.fetch()
+
+	filters := linkedServices(acc, s, hkontroller.SType_FilterMaintenance)
+	if len(filters) > 0 {
+		filter := newServiceChars(app, acc, dev, filters[0])
+		if filter.require(hkontroller.CType_FilterChangeIndication) == nil {
+			filter.optional(
+				hkontroller.CType_FilterLifeLevel,
+				hkontroller.CType_ResetFilterIndication)
+			filter.fetch()
+			a.filter = filter
+		}
+	}
+
+	return a, nil
+}
+
+func (a *AirPurifier) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		a.active.Value = boolValue(value)
+		a.quickActive.Value = a.active.Value
+	case hkontroller.CType_TargetAirPurifierState:
+		a.targetStateEnum.Value = targetFanState2enum(value)
+	case hkontroller.CType_RotationSpeed:
+		if v, ok := floatValue(value); ok {
+			a.speedWidget.Value = float32(v)
+		}
+	}
+}
+
+// LinkedServices returns linked filter maintenance service rendered by this card
+func (a *AirPurifier) LinkedServices() []*hkontroller.ServiceDescription {
+	if a.filter == nil {
+		return nil
+	}
+	return []*hkontroller.ServiceDescription{a.filter.srv}
+}
+
+func (a *AirPurifier) SubscribeToEvents() {
+	a.serviceChars.SubscribeToEvents()
+	if a.filter != nil {
+		a.filter.SubscribeToEvents()
+	}
+}
+
+func (a *AirPurifier) UnsubscribeFromEvents() {
+	a.serviceChars.UnsubscribeFromEvents()
+	if a.filter != nil {
+		a.filter.UnsubscribeFromEvents()
+	}
+}
+
+func (a *AirPurifier) QuickAction() {
+	a.active.Value = !a.active.Value
+	a.quickActive.Value = a.active.Value
+	a.put(hkontroller.CType_Active, bool2num(a.active.Value))
+}
+
+// Alert reports whether filter should be changed
+func (a *AirPurifier) Alert() bool {
+	return a.filter != nil &&
+		boolValue(a.filter.value(hkontroller.CType_FilterChangeIndication))
+}
+
+func (a *AirPurifier) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&a.quickActive, &a.active)
+	if a.active.Changed() {
+		a.put(hkontroller.CType_Active, bool2num(a.active.Value))
+	}
+	for a.targetStateEnum.Changed() {
+		a.put(hkontroller.CType_TargetAirPurifierState, targetFanState2num(a.targetStateEnum.Value))
+	}
+	min, max, step := a.valueRange(hkontroller.CType_RotationSpeed, 0, 100, 1)
+	if sliderDragged(&a.speedWidget) {
+		a.putDebounced(hkontroller.CType_RotationSpeed, speedDragDelay, func() interface{} {
+			return roundToStep(float64(a.speedWidget.Value), min, step)
+		})
+	}
+	for a.resetFilterBtn.Clicked() {
+		if a.filter != nil {
+			a.filter.put(hkontroller.CType_ResetFilterIndication, 1)
+		}
+	}
+
+	stateStr := currentAirPurifierState2str(a.value(hkontroller.CType_CurrentAirPurifierState))
+
+	var filterRows []layout.FlexChild
+	if a.filter != nil {
+		filterStr := "OK"
+		if a.Alert() {
+			filterStr = "change filter"
+		}
+		if a.filter.has(hkontroller.CType_FilterLifeLevel) {
+			filterStr += " | " + withUnit("%")(a.filter.value(hkontroller.CType_FilterLifeLevel))
+		}
+		filterRows = append(filterRows,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(a.th, filterStr)
+				if a.Alert() {
+					lbl.Color = color.NRGBA{R: 200, A: 255}
+				}
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(a.th, "Filter").Layout,
+					lbl.Layout)
+			}))
+	}
+
+	if a.quick {
+		children := []layout.FlexChild{
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Switch(a.th, &a.quickActive, a.label).Layout),
+					layout.Rigid(material.Body1(a.th, " "+stateStr).Layout),
+				)
+			}),
+		}
+		children = append(children, filterRows...)
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(a.th, a.label).Layout,
+				material.Switch(a.th, &a.active, a.label).Layout)
+		}),
+		layout.Rigid(material.Body1(a.th, fmt.Sprintf("State: %s", stateStr)).Layout),
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(material.RadioButton(a.th,
+					&a.targetStateEnum, "manual", "Manual").Layout),
+				layout.Rigid(material.RadioButton(a.th,
+					&a.targetStateEnum, "auto", "Auto").Layout),
+			)
+		}),
+	)
+	if a.has(hkontroller.CType_RotationSpeed) {
+		children = append(children,
+			layout.Rigid(material.Body1(a.th, "Speed").Layout),
+			layout.Rigid(material.Slider(a.th, &a.speedWidget, float32(min), float32(max)).Layout))
+	}
+	children = append(children, filterRows...)
+	if a.filter != nil && a.filter.has(hkontroller.CType_ResetFilterIndication) {
+		children = append(children,
+			layout.Rigid(material.Button(a.th, &a.resetFilterBtn, "Reset filter").Layout))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func currentAirPurifierState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "inactive"
+		case 1:
+			valStr = "idle"
+		case 2:
+			valStr = "purifying"
+		}
+	}
+	return valStr
+}
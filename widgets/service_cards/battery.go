@@ -0,0 +1,151 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+Status Low Battery : 0-Normal 1-Low
+
+Optional Characteristics
+Battery Level : 0-100
+Charging State : 0-Not Charging 1-Charging 2-Not Chargeable
+Name
+*/
+
+// Battery is BatteryService. Quick version is compact badge for accessory card.
+type Battery struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewBattery(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Battery, error) {
+	b := &Battery{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	b.label = label
+
+	err = b.require(hkontroller.CType_StatusLowBattery)
+	if err != nil {
+		return nil, err
+	}
+	b.optional(
+		hkontroller.CType_BatteryLevel,
+		hkontroller.CType_ChargingState)
+	b.fetch()
+
+	return b, nil
+}
+
+// LowBattery reports whether battery should be replaced or charged
+func (b *Battery) LowBattery() bool {
+	return boolValue(b.value(hkontroller.CType_StatusLowBattery))
+}
+
+// Alert reports low battery
+func (b *Battery) Alert() bool {
+	return b.LowBattery()
+}
+
+func (b *Battery) Layout(gtx C) D {
+	levelStr := ""
+	if v, ok := intValue(b.value(hkontroller.CType_BatteryLevel)); ok {
+		levelStr = fmt.Sprintf("%d%%", v)
+	}
+	chargingStr := chargingState2str(b.value(hkontroller.CType_ChargingState))
+
+	if b.quick {
+		text := "battery"
+		if levelStr != "" {
+			text += " " + levelStr
+		}
+		if chargingStr == "charging" {
+			text += " +"
+		}
+		c := color.NRGBA{A: 128}
+		if b.LowBattery() {
+			c = alertColor
+		}
+		return badge(b.th, text, c)(gtx)
+	}
+
+	lowStr := "normal"
+	if b.LowBattery() {
+		lowStr = "low"
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(material.Body1(b.th, "Battery").Layout),
+		layout.Rigid(func(gtx C) D {
+			state := material.Body2(b.th, lowStr)
+			if b.LowBattery() {
+				state.Color = alertColor
+			}
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body2(b.th, "Status").Layout,
+				state.Layout)
+		}))
+	if levelStr != "" {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(b.th, "Level").Layout,
+					material.Body2(b.th, levelStr).Layout)
+			}))
+	}
+	if b.has(hkontroller.CType_ChargingState) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(b.th, "Charging").Layout,
+					material.Body2(b.th, chargingStr).Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func chargingState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "not charging"
+		case 1:
+			valStr = "charging"
+		case 2:
+			valStr = "not chargeable"
+		}
+	}
+	return valStr
+}
@@ -0,0 +1,153 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+// binarySensorSpec describes sensor with detected/not detected state.
+// For all of them value 1 (or true) means detected.
+type binarySensorSpec struct {
+	ctype    hkontroller.HapCharacteristicType
+	name     string
+	detected string
+	clear    string
+	// alarm sensors raise in-app banner on detection
+	alarm bool
+}
+
+var binarySensorSpecs = map[hkontroller.HapServiceType]binarySensorSpec{
+	hkontroller.SType_MotionSensor: {
+		hkontroller.CType_MotionDetected, "Motion", "motion detected", "no motion", false},
+	hkontroller.SType_ContactSensor: {
+		hkontroller.CType_ContactSensorState, "Contact", "open", "closed", false},
+	hkontroller.SType_OccupancySensor: {
+		hkontroller.CType_OccupancyDetected, "Occupancy", "occupied", "not occupied", false},
+	hkontroller.SType_LeakSensor: {
+		hkontroller.CType_LeakDetected, "Leak", "leak detected", "no leak", true},
+	hkontroller.SType_SmokeSensor: {
+		hkontroller.CType_SmokeDetected, "Smoke", "smoke detected", "no smoke", true},
+	hkontroller.SType_CarbonMonoxideSensor: {
+		hkontroller.CType_CarbonMonoxideDetected, "CO", "CO detected", "normal", true},
+}
+
+var alertColor = color.NRGBA{R: 200, A: 255}
+
+// alertTag identifies banner raised by sensor
+type alertTag struct {
+	dev string
+	aid uint64
+	iid uint64
+}
+
+type BinarySensor struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+	spec  binarySensorSpec
+
+	detected bool
+
+	*serviceChars
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewBinarySensor(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*BinarySensor, error) {
+	spec, ok := binarySensorSpecs[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sensor %s", s.Type.String())
+	}
+
+	b := &BinarySensor{
+		quick:        quickWidget,
+		spec:         spec,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	b.serviceChars.onValue = b.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	b.label = label
+
+	err = b.require(spec.ctype)
+	if err != nil {
+		return nil, err
+	}
+	b.optional(hkontroller.CType_CarbonMonoxideLevel)
+	b.optional(sensorStatusChars...)
+	b.fetch()
+
+	return b, nil
+}
+
+func (b *BinarySensor) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	if ctype != b.spec.ctype {
+		return
+	}
+	wasDetected := b.detected
+	b.detected = boolValue(value)
+	if b.detected && !wasDetected && b.spec.alarm {
+		tag := alertTag{dev: b.dev.Name, aid: b.acc.Id, iid: b.chars[ctype].Iid}
+		b.App.Router.ShowBanner(tag, fmt.Sprintf("%s: %s", b.label, b.spec.detected))
+		b.App.Window.Invalidate()
+	}
+}
+
+// Alert reports whether card should be highlighted
+func (b *BinarySensor) Alert() bool {
+	return b.detected
+}
+
+func (b *BinarySensor) Layout(gtx C) D {
+	stateStr := b.spec.clear
+	if b.detected {
+		stateStr = b.spec.detected
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			state := material.Body1(b.th, stateStr)
+			if b.detected {
+				state.Color = alertColor
+			}
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body1(b.th, b.spec.name).Layout,
+				state.Layout)
+		}))
+
+	if !b.quick && b.has(hkontroller.CType_CarbonMonoxideLevel) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(b.th, "CO level").Layout,
+					material.Body2(b.th, withUnit("ppm")(b.value(hkontroller.CType_CarbonMonoxideLevel))).Layout)
+			}))
+	}
+
+	if badges := statusBadges(b.th, b.serviceChars); len(badges) > 0 {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx, badges...)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
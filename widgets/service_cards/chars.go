@@ -0,0 +1,399 @@
+package service_cards
+
+import (
+	"errors"
+	"fmt"
+	"hkapp/application"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gioui.org/widget"
+	"github.com/hkontrol/hkontroller"
+)
+
+// serviceChars holds characteristics of a single service.
+// Values live in application.StateStore, shared by all cards of accessory,
+// serviceChars only mirrors them into characteristic descriptions.
+// Cards embed it to get SubscribeToEvents/UnsubscribeFromEvents.
+type serviceChars struct {
+	acc *hkontroller.Accessory
+	dev *hkontroller.Device
+	srv *hkontroller.ServiceDescription
+
+	chars map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription
+
+	watches map[hkontroller.HapCharacteristicType]application.WatchID
+	// values changed in store after this moment were not seen by card
+	synced time.Time
+
+	// onValue is called for every received value, fetched or from events.
+	// Cached and fetched values come as SourcePoll.
+	onValue func(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source)
+
+	dragTimers map[hkontroller.HapCharacteristicType]*time.Timer
+
+	app *application.App
+}
+
+func newServiceChars(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	srv *hkontroller.ServiceDescription) *serviceChars {
+	return &serviceChars{
+		acc:        acc,
+		dev:        dev,
+		srv:        srv,
+		app:        app,
+		chars:      make(map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription),
+		watches:    make(map[hkontroller.HapCharacteristicType]application.WatchID),
+		synced:     time.Now(),
+		dragTimers: make(map[hkontroller.HapCharacteristicType]*time.Timer),
+	}
+}
+
+// require adds characteristics which service cannot work without
+func (c *serviceChars) require(ctypes ...hkontroller.HapCharacteristicType) error {
+	for _, ctype := range ctypes {
+		cc := c.srv.GetCharacteristic(ctype)
+		if cc == nil {
+			return fmt.Errorf("cannot find required characteristic %s", ctype.String())
+		}
+		c.chars[ctype] = cc
+	}
+	return nil
+}
+
+// optional adds characteristics if service has them
+func (c *serviceChars) optional(ctypes ...hkontroller.HapCharacteristicType) {
+	for _, ctype := range ctypes {
+		cc := c.srv.GetCharacteristic(ctype)
+		if cc != nil {
+			c.chars[ctype] = cc
+		}
+	}
+}
+
+func (c *serviceChars) has(ctype hkontroller.HapCharacteristicType) bool {
+	_, ok := c.chars[ctype]
+	return ok
+}
+
+func (c *serviceChars) value(ctype hkontroller.HapCharacteristicType) interface{} {
+	cc, ok := c.chars[ctype]
+	if !ok {
+		return nil
+	}
+	return cc.Value
+}
+
+func (c *serviceChars) setValue(value interface{}, ctype hkontroller.HapCharacteristicType,
+	source application.Source) {
+	if cc, ok := c.chars[ctype]; ok {
+		cc.Value = value
+	}
+	if c.onValue != nil {
+		c.onValue(value, ctype, source)
+	}
+}
+
+// fetch shows values known so far and requests the rest off the UI goroutine,
+// all characteristics of service in one batch. Card is filled in when results arrive.
+func (c *serviceChars) fetch() {
+	ctypes := make(map[application.CharID]hkontroller.HapCharacteristicType)
+	var ids []application.CharID
+	for ctype, cdescr := range c.chars {
+		if !HasPerm(cdescr, PermRead) {
+			continue
+		}
+		id := application.CharID{Aid: c.acc.Id, Iid: cdescr.Iid}
+		ctypes[id] = ctype
+		ids = append(ids, id)
+		// value from accessory description until read completes
+		if cdescr.Value != nil {
+			c.setValue(cdescr.Value, ctype, application.SourcePoll)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	known := c.app.State.LoadBatch(c.dev, ids, func(res map[application.CharID]application.ReadResult) {
+		for id, r := range res {
+			if r.Err == nil {
+				c.setValue(r.State.Value.Raw(), ctypes[id], application.SourcePoll)
+			}
+		}
+	})
+	for id, st := range known {
+		c.setValue(st.Value.Raw(), ctypes[id], application.SourcePoll)
+	}
+}
+
+// SubscribeToEvents starts watching values in the store.
+// Store shares single HAP subscription between all cards showing characteristic.
+func (c *serviceChars) SubscribeToEvents() {
+	for ctype, cdescr := range c.chars {
+		if _, ok := c.watches[ctype]; ok {
+			continue
+		}
+		ctype, cdescr := ctype, cdescr
+		events := HasPerm(cdescr, PermEvents)
+		if EventOnly(cdescr) {
+			c.app.State.MarkEventOnly(c.dev, c.acc.Id, cdescr.Iid)
+		}
+		c.watches[ctype] = c.app.State.Watch(c.dev, c.acc.Id, cdescr.Iid, events,
+			func(ev application.ValueEvent) {
+				if ev.Echo(c) {
+					// widget already shows value it has written
+					cdescr.Value = ev.Value.Raw()
+					return
+				}
+				c.setValue(ev.Value.Raw(), ctype, ev.Source)
+			})
+		// value may have changed while card was not watching
+		if st, ok := c.app.State.Get(c.dev, c.acc.Id, cdescr.Iid); ok && st.Updated.After(c.synced) {
+			c.setValue(st.Value.Raw(), ctype, application.SourcePoll)
+		}
+	}
+}
+
+func (c *serviceChars) UnsubscribeFromEvents() {
+	for ctype, id := range c.watches {
+		c.app.State.Unwatch(id)
+		delete(c.watches, ctype)
+	}
+	c.synced = time.Now()
+}
+
+// put writes value optimistically: card and store show it at once,
+// failed write is rolled back and reported in snackbar
+func (c *serviceChars) put(ctype hkontroller.HapCharacteristicType, value interface{}) error {
+	return c.putMany(map[hkontroller.HapCharacteristicType]interface{}{ctype: value})
+}
+
+// putMany writes several characteristics of service in one batch
+func (c *serviceChars) putMany(values map[hkontroller.HapCharacteristicType]interface{}) error {
+	batch := make(map[application.CharID]interface{}, len(values))
+	var ctypes []hkontroller.HapCharacteristicType
+	for ctype, value := range values {
+		cc, ok := c.chars[ctype]
+		if !ok {
+			return errors.New("cannot find characteristic " + ctype.String())
+		}
+		if !HasPerm(cc, PermRead) {
+			c.app.State.MarkWriteOnly(c.dev, c.acc.Id, cc.Iid)
+		}
+		cc.Value = value
+		batch[application.CharID{Aid: c.acc.Id, Iid: cc.Iid}] = value
+		ctypes = append(ctypes, ctype)
+	}
+	c.app.WriteValues(c.dev, batch, c, c.describe(ctypes...))
+	return nil
+}
+
+// describe names characteristics for user messages
+func (c *serviceChars) describe(ctypes ...hkontroller.HapCharacteristicType) string {
+	names := make([]string, 0, len(ctypes))
+	for _, ctype := range ctypes {
+		names = append(names, ctype.String())
+	}
+	sort.Strings(names)
+	what := strings.Join(names, ", ")
+	name, err := accessoryName(c.acc)
+	if err != nil {
+		return what
+	}
+	return name + " " + what
+}
+
+// pending reports whether written value is not confirmed by device yet
+func (c *serviceChars) pending(ctype hkontroller.HapCharacteristicType) bool {
+	cc, ok := c.chars[ctype]
+	if !ok {
+		return false
+	}
+	st, ok := c.app.State.Get(c.dev, c.acc.Id, cc.Iid)
+	return ok && st.Pending
+}
+
+// putDebounced writes value only when it stays unchanged for delay,
+// so dragging slider doesn't flood device with requests
+func (c *serviceChars) putDebounced(ctype hkontroller.HapCharacteristicType,
+	delay time.Duration, value func() interface{}) {
+	c.putManyDebounced(ctype, delay, func() map[hkontroller.HapCharacteristicType]interface{} {
+		return map[hkontroller.HapCharacteristicType]interface{}{ctype: value()}
+	})
+}
+
+// putManyDebounced is putDebounced for control changing several characteristics,
+// key identifies the control
+func (c *serviceChars) putManyDebounced(key hkontroller.HapCharacteristicType,
+	delay time.Duration, values func() map[hkontroller.HapCharacteristicType]interface{}) {
+	if t, ok := c.dragTimers[key]; ok {
+		t.Stop()
+	}
+	c.dragTimers[key] = time.AfterFunc(delay, func() {
+		c.putMany(values())
+	})
+}
+
+// valueRange returns min, max and step of numeric characteristic
+// falling back to provided defaults if device doesn't specify them
+func (c *serviceChars) valueRange(ctype hkontroller.HapCharacteristicType,
+	min, max, step float64) (float64, float64, float64) {
+	cc, ok := c.chars[ctype]
+	if !ok {
+		return min, max, step
+	}
+	if v, ok := floatValue(cc.MinValue); ok {
+		min = v
+	}
+	if v, ok := floatValue(cc.MaxValue); ok {
+		max = v
+	}
+	if v, ok := floatValue(cc.MinStep); ok && v > 0 {
+		step = v
+	}
+	return min, max, step
+}
+
+// HAP characteristic permissions
+const (
+	PermRead   = "pr"
+	PermWrite  = "pw"
+	PermEvents = "ev"
+)
+
+// HasPerm reports whether characteristic has permission.
+// Characteristics without declared permissions are treated as permitting everything.
+func HasPerm(cc *hkontroller.CharacteristicDescription, perm string) bool {
+	if len(cc.Permissions) == 0 {
+		return true
+	}
+	for _, p := range cc.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// EventOnly reports whether characteristic carries momentary events
+// instead of state, reading it returns null or the last event
+func EventOnly(cc *hkontroller.CharacteristicDescription) bool {
+	return cc.Type == hkontroller.CType_ProgrammableSwitchEvent
+}
+
+// util
+// ------------------
+func roundToStep(v, min, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return min + math.Round((v-min)/step)*step
+}
+
+// sliderDragged reports whether user moved slider. Float also reports change
+// when it clamps value received from device into slider range,
+// such value must not be written back.
+func sliderDragged(f *widget.Float) bool {
+	return f.Changed() && f.Dragging()
+}
+
+// mirrorQuickSwitch keeps quick switch showing value of full one.
+// Click on quick switch reaches card as well, which runs QuickAction,
+// so toggle of the switch itself is dropped instead of written again.
+func mirrorQuickSwitch(quick, full *widget.Bool) {
+	if quick.Changed() {
+		quick.Value = full.Value
+	}
+}
+
+// valueStr formats value for display, placeholder until it is read
+func valueStr(v interface{}) string {
+	if v == nil {
+		return "–"
+	}
+	return application.ValueOf(v).String()
+}
+
+// withUnit returns formatter of numeric value followed by unit,
+// other values are formatted by valueStr
+func withUnit(unit string) func(v interface{}) string {
+	return func(v interface{}) string {
+		if f, ok := floatValue(v); ok {
+			return fmt.Sprintf("%.1f %s", f, unit)
+		}
+		return valueStr(v)
+	}
+}
+
+func accessoryName(acc *hkontroller.Accessory) (string, error) {
+	infoS := acc.GetService(hkontroller.SType_AccessoryInfo)
+	if infoS == nil {
+		return "", errors.New("cannot get AccessoryInfo service")
+	}
+	labelC := infoS.GetCharacteristic(hkontroller.CType_Name)
+	if labelC == nil {
+		return "", errors.New("cannot get characteristic Name")
+	}
+	label, ok := labelC.Value.(string)
+	if !ok {
+		return "", errors.New("cannot extract accessory name")
+	}
+	return label, nil
+}
+
+// linkableTypes are services that cards may render as part of another service
+var linkableTypes = map[string]bool{
+	normalizeType(string(hkontroller.SType_InputSource)):       true,
+	normalizeType(string(hkontroller.SType_TelevisionSpeaker)): true,
+	normalizeType(string(hkontroller.SType_Speaker)):           true,
+	normalizeType(string(hkontroller.SType_Valve)):             true,
+	normalizeType(string(hkontroller.SType_FilterMaintenance)): true,
+}
+
+// IsLinkable reports whether service may be rendered by card of another service
+func IsLinkable(stype hkontroller.HapServiceType) bool {
+	return linkableTypes[normalizeType(string(stype))]
+}
+
+// linkedServices returns services of given type linked to s.
+// Accessories which don't declare links get all services of that type.
+func linkedServices(acc *hkontroller.Accessory,
+	s *hkontroller.ServiceDescription,
+	stype hkontroller.HapServiceType) []*hkontroller.ServiceDescription {
+	var res []*hkontroller.ServiceDescription
+	for _, iid := range s.Linked {
+		for _, ls := range acc.Ss {
+			if ls.Iid == iid && ls.Type == stype {
+				res = append(res, ls)
+			}
+		}
+	}
+	if len(s.Linked) > 0 {
+		return res
+	}
+	for _, ls := range acc.Ss {
+		if ls.Type == stype {
+			res = append(res, ls)
+		}
+	}
+	return res
+}
+
+// boolValue converts HAP bool, which may come as a number as well
+func boolValue(v interface{}) bool {
+	return application.ValueOf(v).Bool()
+}
+
+// floatValue converts any HAP numeric value to float64
+func floatValue(v interface{}) (float64, bool) {
+	return application.ValueOf(v).Float()
+}
+
+// intValue is floatValue for enum-like characteristics
+func intValue(v interface{}) (int, bool) {
+	return application.ValueOf(v).Int()
+}
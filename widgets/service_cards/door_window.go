@@ -0,0 +1,104 @@
+package service_cards
+
+import (
+	"hkapp/application"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Door, Window
+Required Characteristics
+Current Position : 0-100
+Target Position : 0-100
+Position State : 0-Decreasing 1-Increasing 2-Stopped
+
+Optional Characteristics
+Name
+Hold Position
+Obstruction Detected
+*/
+
+// DoorWindow is Door or Window service
+type DoorWindow struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	pos *positionControl
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewDoor(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*DoorWindow, error) {
+	return newDoorWindow(app, acc, dev, s, quickWidget)
+}
+
+func NewWindow(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*DoorWindow, error) {
+	return newDoorWindow(app, acc, dev, s, quickWidget)
+}
+
+func newDoorWindow(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*DoorWindow, error) {
+	d := &DoorWindow{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	d.serviceChars.onValue = d.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	d.label = label
+
+	err = d.require(
+		hkontroller.CType_CurrentPosition,
+		hkontroller.CType_TargetPosition,
+		hkontroller.CType_PositionState)
+	if err != nil {
+		return nil, err
+	}
+	d.optional(
+		hkontroller.CType_HoldPosition,
+		hkontroller.CType_ObstructionDetected)
+	d.pos = newPositionControl(d.serviceChars, d.th)
+	d.fetch()
+
+	return d, nil
+}
+
+func (d *DoorWindow) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	d.pos.onValue(value, ctype, source)
+}
+
+// QuickAction fully opens door or window, or closes it if it is open or opening
+func (d *DoorWindow) QuickAction() {
+	d.pos.quickAction()
+}
+
+func (d *DoorWindow) Layout(gtx C) D {
+
+	d.pos.update()
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, d.pos.layout(d.quick)...)
+}
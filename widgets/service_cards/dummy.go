@@ -3,7 +3,6 @@ package service_cards
 import (
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 	"hkapp/application"
 )
 
@@ -13,8 +12,6 @@ type Dummy struct {
 	acc *hkontroller.Accessory
 	dev *hkontroller.Device
 
-	events <-chan emitter.Event
-
 	th *material.Theme
 
 	*application.App
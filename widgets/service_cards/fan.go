@@ -0,0 +1,293 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const speedDragDelay = 300 * time.Millisecond
+
+/*
+Fan
+Required Characteristics
+9.70 On
+
+Optional Characteristics
+9.80 Rotation Direction : 0-Clockwise 1-CounterClockwise
+9.81 Rotation Speed : 0-100
+
+Fan v2
+Required Characteristics
+9.1 Active : 0-Inactive 1-Active
+
+Optional Characteristics
+9.24 Current Fan State : 0-Inactive 1-Idle 2-Blowing Air
+9.107 Target Fan State : 0-Manual 1-Auto
+9.51 Lock Physical Controls : 0-Disabled 1-Enabled
+9.80 Rotation Direction
+9.81 Rotation Speed
+9.99 Swing Mode : 0-Disabled 1-Enabled
+*/
+
+type Fan struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	// On for Fan, Active for Fan v2
+	powerC hkontroller.HapCharacteristicType
+
+	*serviceChars
+
+	on              widget.Bool
+	quickOn         widget.Bool
+	speedWidget     widget.Float
+	directionEnum   widget.Enum
+	targetStateEnum widget.Enum
+	swing           widget.Bool
+	lockControls    widget.Bool
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewFan(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Fan, error) {
+	f := &Fan{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+		powerC:       hkontroller.CType_On,
+	}
+	f.serviceChars.onValue = f.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	f.label = label
+
+	if s.Type == hkontroller.SType_FanV2 {
+		f.powerC = hkontroller.CType_Active
+	}
+	err = f.require(f.powerC)
+	if err != nil {
+		return nil, err
+	}
+	f.optional(
+		hkontroller.CType_RotationDirection,
+		hkontroller.CType_RotationSpeed,
+		hkontroller.CType_CurrentFanState,
+		hkontroller.CType_TargetFanState,
+		hkontroller.CType_LockPhysicalControls,
+		hkontroller.CType_SwingMode)
+	f.fetch()
+
+	return f, nil
+}
+
+func (f *Fan) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case f.powerC:
+		f.on.Value = boolValue(value)
+		f.quickOn.Value = f.on.Value
+	case hkontroller.CType_RotationSpeed:
+		if v, ok := floatValue(value); ok {
+			f.speedWidget.Value = float32(v)
+		}
+	case hkontroller.CType_RotationDirection:
+		f.directionEnum.Value = rotationDirection2enum(value)
+	case hkontroller.CType_TargetFanState:
+		f.targetStateEnum.Value = targetFanState2enum(value)
+	case hkontroller.CType_SwingMode:
+		f.swing.Value = boolValue(value)
+	case hkontroller.CType_LockPhysicalControls:
+		f.lockControls.Value = boolValue(value)
+	}
+}
+
+// powerValue converts bool to value suitable for On or Active
+func (f *Fan) powerValue(on bool) interface{} {
+	if f.powerC == hkontroller.CType_On {
+		return on
+	}
+	return bool2num(on)
+}
+
+func (f *Fan) QuickAction() {
+	f.on.Value = !f.on.Value
+	f.quickOn.Value = f.on.Value
+	f.put(f.powerC, f.powerValue(f.on.Value))
+}
+
+func (f *Fan) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&f.quickOn, &f.on)
+	if f.on.Changed() {
+		f.put(f.powerC, f.powerValue(f.on.Value))
+	}
+	min, max, step := f.valueRange(hkontroller.CType_RotationSpeed, 0, 100, 1)
+	if sliderDragged(&f.speedWidget) {
+		f.putDebounced(hkontroller.CType_RotationSpeed, speedDragDelay, func() interface{} {
+			return roundToStep(float64(f.speedWidget.Value), min, step)
+		})
+	}
+	for f.directionEnum.Changed() {
+		f.put(hkontroller.CType_RotationDirection, rotationDirection2num(f.directionEnum.Value))
+	}
+	for f.targetStateEnum.Changed() {
+		f.put(hkontroller.CType_TargetFanState, targetFanState2num(f.targetStateEnum.Value))
+	}
+	if f.swing.Changed() {
+		f.put(hkontroller.CType_SwingMode, bool2num(f.swing.Value))
+	}
+	if f.lockControls.Changed() {
+		f.put(hkontroller.CType_LockPhysicalControls, bool2num(f.lockControls.Value))
+	}
+
+	speedStr := ""
+	if f.has(hkontroller.CType_RotationSpeed) {
+		if v, ok := floatValue(f.value(hkontroller.CType_RotationSpeed)); ok && max > min {
+			speedStr = fmt.Sprintf(" %d%%", int((v-min)/(max-min)*100))
+		}
+	}
+
+	if f.quick {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(material.Switch(f.th, &f.quickOn, f.label).Layout),
+			layout.Rigid(material.Body1(f.th, speedStr).Layout),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(f.th, f.label).Layout,
+				material.Switch(f.th, &f.on, f.label).Layout)
+		}))
+	if f.has(hkontroller.CType_CurrentFanState) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(f.th, "State").Layout,
+					material.Body2(f.th, currentFanState2str(f.value(hkontroller.CType_CurrentFanState))).Layout)
+			}))
+	}
+	if f.has(hkontroller.CType_RotationSpeed) {
+		children = append(children,
+			layout.Rigid(material.Body1(f.th, "Speed"+speedStr).Layout),
+			layout.Rigid(material.Slider(f.th, &f.speedWidget, float32(min), float32(max)).Layout))
+	}
+	if f.has(hkontroller.CType_RotationDirection) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.RadioButton(f.th,
+						&f.directionEnum, "cw", "Clockwise").Layout),
+					layout.Rigid(material.RadioButton(f.th,
+						&f.directionEnum, "ccw", "Counter-clockwise").Layout),
+				)
+			}))
+	}
+	if f.has(hkontroller.CType_TargetFanState) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.RadioButton(f.th,
+						&f.targetStateEnum, "manual", "Manual").Layout),
+					layout.Rigid(material.RadioButton(f.th,
+						&f.targetStateEnum, "auto", "Auto").Layout),
+				)
+			}))
+	}
+	if f.has(hkontroller.CType_SwingMode) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+					material.Body1(f.th, "Swing").Layout,
+					material.Switch(f.th, &f.swing, "Swing").Layout)
+			}))
+	}
+	if f.has(hkontroller.CType_LockPhysicalControls) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+					material.Body1(f.th, "Lock controls").Layout,
+					material.Switch(f.th, &f.lockControls, "Lock controls").Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func bool2num(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+func rotationDirection2enum(v interface{}) string {
+	valStr := ""
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "cw"
+		case 1:
+			valStr = "ccw"
+		}
+	}
+	return valStr
+}
+func rotationDirection2num(v string) int {
+	if v == "ccw" {
+		return 1
+	}
+	return 0
+}
+func targetFanState2enum(v interface{}) string {
+	valStr := ""
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "manual"
+		case 1:
+			valStr = "auto"
+		}
+	}
+	return valStr
+}
+func targetFanState2num(v string) int {
+	if v == "auto" {
+		return 1
+	}
+	return 0
+}
+func currentFanState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "inactive"
+		case 1:
+			valStr = "idle"
+		case 2:
+			valStr = "blowing air"
+		}
+	}
+	return valStr
+}
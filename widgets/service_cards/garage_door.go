@@ -0,0 +1,228 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+// time for second tap on quick card to confirm opening
+const openConfirmTimeout = 3 * time.Second
+
+/*
+Required Characteristics
+9.30 Current Door State : 0-Open 1-Closed 2-Opening 3-Closing 4-Stopped
+9.118 Target Door State : 0-Open 1-Closed
+9.65 Obstruction Detected
+
+Optional Characteristics
+9.52 Lock Current State : 0-Unsecured 1-Secured 2-Jammed 3-Unknown
+9.56 Lock Target State : 0-Unsecured 1-Secured
+9.62 Name
+*/
+
+const (
+	doorOpen   = 0
+	doorClosed = 1
+)
+
+type GarageDoorOpener struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	openBtn  widget.Clickable
+	closeBtn widget.Clickable
+
+	confirmOpen    bool
+	confirmOpenBtn widget.Clickable
+	cancelOpenBtn  widget.Clickable
+	// quick card is confirmed by second tap
+	openArmedUntil time.Time
+
+	locked widget.Bool
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewGarageDoorOpener(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*GarageDoorOpener, error) {
+	g := &GarageDoorOpener{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	g.serviceChars.onValue = g.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	g.label = label
+
+	err = g.require(
+		hkontroller.CType_CurrentDoorState,
+		hkontroller.CType_TargetDoorState,
+		hkontroller.CType_ObstructionDetected)
+	if err != nil {
+		return nil, err
+	}
+	g.optional(
+		hkontroller.CType_LockCurrentState,
+		hkontroller.CType_LockTargetState)
+	g.fetch()
+
+	return g, nil
+}
+
+func (g *GarageDoorOpener) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	if ctype == hkontroller.CType_LockTargetState {
+		v, _ := intValue(value)
+		g.locked.Value = v == lockSecured
+	}
+}
+
+// QuickAction closes the door at once, opening needs second tap
+func (g *GarageDoorOpener) QuickAction() {
+	if t, ok := intValue(g.value(hkontroller.CType_TargetDoorState)); ok && t == doorOpen {
+		g.openArmedUntil = time.Time{}
+		g.put(hkontroller.CType_TargetDoorState, doorClosed)
+		return
+	}
+	if time.Now().Before(g.openArmedUntil) {
+		g.openArmedUntil = time.Time{}
+		g.put(hkontroller.CType_TargetDoorState, doorOpen)
+		return
+	}
+	g.openArmedUntil = time.Now().Add(openConfirmTimeout)
+	time.AfterFunc(openConfirmTimeout, g.App.Window.Invalidate)
+	g.App.Window.Invalidate()
+}
+
+func (g *GarageDoorOpener) Layout(gtx C) D {
+
+	for g.closeBtn.Clicked() {
+		g.confirmOpen = false
+		g.put(hkontroller.CType_TargetDoorState, doorClosed)
+	}
+	for g.openBtn.Clicked() {
+		g.confirmOpen = true
+	}
+	for g.cancelOpenBtn.Clicked() {
+		g.confirmOpen = false
+	}
+	for g.confirmOpenBtn.Clicked() {
+		g.confirmOpen = false
+		g.put(hkontroller.CType_TargetDoorState, doorOpen)
+	}
+	if g.locked.Changed() {
+		lockValue := lockUnsecured
+		if g.locked.Value {
+			lockValue = lockSecured
+		}
+		g.put(hkontroller.CType_LockTargetState, lockValue)
+	}
+
+	currentStr := doorState2str(g.value(hkontroller.CType_CurrentDoorState))
+	targetStr := doorState2str(g.value(hkontroller.CType_TargetDoorState))
+	obstructed := boolValue(g.value(hkontroller.CType_ObstructionDetected))
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(material.Body1(g.th,
+			fmt.Sprintf("Door: %s | Target: %s", currentStr, targetStr)).Layout))
+	if obstructed {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body1(g.th, "Obstruction detected!")
+				lbl.Color = color.NRGBA{R: 200, A: 255}
+				return lbl.Layout(gtx)
+			}))
+	}
+
+	if g.quick {
+		if time.Now().Before(g.openArmedUntil) {
+			children = append(children,
+				layout.Rigid(material.Body2(g.th, "tap again to open").Layout))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	if g.confirmOpen {
+		children = append(children,
+			layout.Rigid(material.Body1(g.th, fmt.Sprintf("Open %s?", g.label)).Layout),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Button(g.th, &g.confirmOpenBtn, "Open").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(g.th, &g.cancelOpenBtn, "Cancel").Layout),
+				)
+			}))
+	} else {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Button(g.th, &g.openBtn, "Open").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(g.th, &g.closeBtn, "Close").Layout),
+				)
+			}))
+	}
+
+	if g.has(hkontroller.CType_LockCurrentState) {
+		lockStr := lockCurrentState2str(g.value(hkontroller.CType_LockCurrentState))
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(g.th, "Lock").Layout,
+					material.Body2(g.th, lockStr).Layout)
+			}))
+	}
+	if g.has(hkontroller.CType_LockTargetState) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+					material.Body1(g.th, "Locked").Layout,
+					material.Switch(g.th, &g.locked, "Locked").Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func doorState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "open"
+		case 1:
+			valStr = "closed"
+		case 2:
+			valStr = "opening"
+		case 3:
+			valStr = "closing"
+		case 4:
+			valStr = "stopped"
+		}
+	}
+	return valStr
+}
@@ -0,0 +1,321 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"strconv"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const genericDragDelay = 300 * time.Millisecond
+
+// HAP characteristic permission for hidden characteristics
+const permHidden = "hd"
+
+// number of characteristics shown on quick card
+const genericQuickRows = 2
+
+// kinds of controls picked from characteristic metadata
+const (
+	controlText = iota
+	controlToggle
+	controlButton
+	controlSlider
+	controlEnum
+)
+
+// genericControl is control for single characteristic
+type genericControl struct {
+	cc   *hkontroller.CharacteristicDescription
+	name string
+	kind int
+
+	min, max, step float64
+
+	toggle widget.Bool
+	button widget.Clickable
+	slider widget.Float
+	enum   widget.Enum
+}
+
+// GenericService is fallback card for services without dedicated one.
+// Controls are picked from format, permissions and value constraints of characteristics.
+type GenericService struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	controls []*genericControl
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewGenericService(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*GenericService, error) {
+	g := &GenericService{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+		label:        s.Type.String(),
+	}
+	g.serviceChars.onValue = g.onValue
+
+	for _, cc := range s.Cs {
+		if cc.Type == hkontroller.CType_Name {
+			if name, ok := cc.Value.(string); ok && name != "" {
+				g.label = name
+			}
+			continue
+		}
+		if hasPermExplicit(cc, permHidden) {
+			continue
+		}
+		g.chars[cc.Type] = cc
+		g.controls = append(g.controls, newGenericControl(cc))
+	}
+	g.fetch()
+
+	return g, nil
+}
+
+func newGenericControl(cc *hkontroller.CharacteristicDescription) *genericControl {
+	gc := &genericControl{
+		cc:   cc,
+		name: cc.Description,
+		kind: controlText,
+	}
+	if gc.name == "" {
+		gc.name = cc.Type.String()
+	}
+
+	writable := HasPerm(cc, PermWrite)
+	if !writable {
+		return gc
+	}
+
+	switch cc.Format {
+	case "bool":
+		if HasPerm(cc, PermRead) {
+			gc.kind = controlToggle
+		} else {
+			// write-only, like Identify
+			gc.kind = controlButton
+		}
+	case "uint8", "uint16", "uint32", "uint64", "int", "float":
+		min, minOk := floatValue(cc.MinValue)
+		max, maxOk := floatValue(cc.MaxValue)
+		step, stepOk := floatValue(cc.MinStep)
+		if !stepOk || step <= 0 {
+			step = 1
+			if cc.Format == "float" {
+				step = 0.1
+			}
+		}
+		gc.min, gc.max, gc.step = min, max, step
+		switch {
+		case len(cc.ValidValues) > 0:
+			gc.kind = controlEnum
+		case minOk && maxOk && max > min:
+			gc.kind = controlSlider
+		}
+	}
+	return gc
+}
+
+func (g *GenericService) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	for _, gc := range g.controls {
+		if gc.cc.Type != ctype {
+			continue
+		}
+		switch gc.kind {
+		case controlToggle:
+			gc.toggle.Value = boolValue(value)
+		case controlSlider:
+			if v, ok := floatValue(value); ok {
+				gc.slider.Value = float32(v)
+			}
+		case controlEnum:
+			if v, ok := intValue(value); ok {
+				gc.enum.Value = strconv.Itoa(v)
+			}
+		}
+	}
+}
+
+func (g *GenericService) update() {
+	for _, gc := range g.controls {
+		gc := gc
+		ctype := gc.cc.Type
+		switch gc.kind {
+		case controlToggle:
+			if gc.toggle.Changed() {
+				g.put(ctype, gc.toggle.Value)
+			}
+		case controlButton:
+			for gc.button.Clicked() {
+				g.put(ctype, true)
+			}
+		case controlSlider:
+			if sliderDragged(&gc.slider) {
+				g.putDebounced(ctype, genericDragDelay, func() interface{} {
+					v := roundToStep(float64(gc.slider.Value), gc.min, gc.step)
+					if gc.cc.Format != "float" {
+						return int(v)
+					}
+					return v
+				})
+			}
+		case controlEnum:
+			for gc.enum.Changed() {
+				if v, err := strconv.Atoi(gc.enum.Value); err == nil {
+					g.put(ctype, v)
+				}
+			}
+		}
+	}
+}
+
+func (g *GenericService) Layout(gtx C) D {
+
+	g.update()
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(material.Body1(g.th, g.label).Layout))
+
+	rows := 0
+	for _, gc := range g.controls {
+		gc := gc
+		if g.quick {
+			// only readable values on quick card
+			if rows >= genericQuickRows || !HasPerm(gc.cc, PermRead) {
+				continue
+			}
+			rows++
+			children = append(children, layout.Rigid(g.layoutValue(gc, false)))
+			continue
+		}
+
+		switch gc.kind {
+		case controlToggle:
+			children = append(children,
+				layout.Rigid(func(gtx C) D {
+					return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+						material.Body2(g.th, gc.name).Layout,
+						material.Switch(g.th, &gc.toggle, gc.name).Layout)
+				}))
+		case controlButton:
+			children = append(children,
+				layout.Rigid(material.Button(g.th, &gc.button, gc.name).Layout))
+		case controlSlider:
+			children = append(children,
+				layout.Rigid(g.layoutValue(gc, true)),
+				layout.Rigid(material.Slider(g.th, &gc.slider, float32(gc.min), float32(gc.max)).Layout))
+		case controlEnum:
+			var options []layout.FlexChild
+			for _, v := range gc.cc.ValidValues {
+				key := strconv.Itoa(v)
+				options = append(options,
+					layout.Rigid(material.RadioButton(g.th, &gc.enum, key, key).Layout))
+			}
+			children = append(children,
+				layout.Rigid(material.Body2(g.th, gc.name).Layout),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{}.Layout(gtx, options...)
+				}))
+		default:
+			children = append(children, layout.Rigid(g.layoutValue(gc, true)))
+		}
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// layoutValue lays out characteristic value as read-only text
+func (g *GenericService) layoutValue(gc *genericControl, full bool) layout.Widget {
+	return func(gtx C) D {
+		valStr := genericValue2str(gc.cc)
+		if g.pending(gc.cc.Type) {
+			valStr += " (pending)"
+		}
+		if full {
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body2(g.th, gc.name).Layout,
+				material.Body2(g.th, valStr).Layout)
+		}
+		return material.Body2(g.th, fmt.Sprintf("%s: %s", gc.name, valStr)).Layout(gtx)
+	}
+}
+
+// util
+// ------------------
+
+// hasPermExplicit is HasPerm without treating empty permissions as all allowed
+func hasPermExplicit(cc *hkontroller.CharacteristicDescription, perm string) bool {
+	return len(cc.Permissions) > 0 && HasPerm(cc, perm)
+}
+
+func genericValue2str(cc *hkontroller.CharacteristicDescription) string {
+	if !HasPerm(cc, PermRead) {
+		return "write only"
+	}
+	switch cc.Format {
+	case "tlv8", "data":
+		return "<" + cc.Format + ">"
+	case "bool":
+		if cc.Value == nil {
+			return "--"
+		}
+		if boolValue(cc.Value) {
+			return "yes"
+		}
+		return "no"
+	case "string":
+		if s, ok := cc.Value.(string); ok {
+			return s
+		}
+		return "--"
+	}
+	v, ok := floatValue(cc.Value)
+	if !ok {
+		if cc.Value == nil {
+			return "--"
+		}
+		return fmt.Sprintf("%v", cc.Value)
+	}
+	valStr := strconv.FormatFloat(v, 'f', -1, 64)
+	if u := unit2str(cc.Unit); u != "" {
+		valStr += " " + u
+	}
+	return valStr
+}
+
+func unit2str(u string) string {
+	switch u {
+	case "celsius":
+		return "°C"
+	case "percentage":
+		return "%"
+	case "arcdegrees":
+		return "°"
+	case "lux":
+		return "lx"
+	case "seconds":
+		return "s"
+	}
+	return u
+}
@@ -0,0 +1,271 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"math"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+9.1 Active : 0-Inactive 1-Active
+9.35 Current Temperature
+9.111 Target Heater Cooler State : 0-Auto 1-Heat 2-Cool
+9.110 Current Heater Cooler State : 0-Inactive 1-Idle 2-Heating 3-Cooling
+
+Optional Characteristics
+9.62 Name
+9.81 Rotation Speed
+9.122 Temperature Display Units
+9.99 Swing Mode : 0-Disabled 1-Enabled
+9.20 Cooling Threshold Temperature : 10-35
+9.42 Heating Threshold Temperature : 0-25
+9.51 Lock Physical Controls
+*/
+
+type HeaterCooler struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	active         widget.Bool
+	quickActive    widget.Bool
+	targetModeEnum widget.Enum
+	coolingWidget  widget.Float
+	heatingWidget  widget.Float
+	speedWidget    widget.Float
+	swing          widget.Bool
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewHeaterCooler(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*HeaterCooler, error) {
+	h := &HeaterCooler{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	h.serviceChars.onValue = h.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	h.label = label
+
+	err = h.require(
+		hkontroller.CType_Active,
+		hkontroller.CType_CurrentTemperature,
+		hkontroller.CType_TargetHeaterCoolerState,
+		hkontroller.CType_CurrentHeaterCoolerState)
+	if err != nil {
+		return nil, err
+	}
+	h.optional(
+		hkontroller.CType_RotationSpeed,
+		hkontroller.CType_SwingMode,
+		hkontroller.CType_CoolingThresholdTemperature,
+		hkontroller.CType_HeatingThresholdTemperature)
+	h.fetch()
+
+	return h, nil
+}
+
+func (h *HeaterCooler) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		h.active.Value = boolValue(value)
+		h.quickActive.Value = h.active.Value
+	case hkontroller.CType_TargetHeaterCoolerState:
+		h.targetModeEnum.Value = targetHeaterCoolerState2enum(value)
+	case hkontroller.CType_CoolingThresholdTemperature:
+		if v, ok := floatValue(value); ok {
+			h.coolingWidget.Value = float32(v)
+		}
+	case hkontroller.CType_HeatingThresholdTemperature:
+		if v, ok := floatValue(value); ok {
+			h.heatingWidget.Value = float32(v)
+		}
+	case hkontroller.CType_RotationSpeed:
+		if v, ok := floatValue(value); ok {
+			h.speedWidget.Value = float32(v)
+		}
+	case hkontroller.CType_SwingMode:
+		h.swing.Value = boolValue(value)
+	}
+}
+
+func (h *HeaterCooler) QuickAction() {
+	h.active.Value = !h.active.Value
+	h.quickActive.Value = h.active.Value
+	h.put(hkontroller.CType_Active, bool2num(h.active.Value))
+}
+
+func (h *HeaterCooler) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&h.quickActive, &h.active)
+	if h.active.Changed() {
+		h.put(hkontroller.CType_Active, bool2num(h.active.Value))
+	}
+	for h.targetModeEnum.Changed() {
+		h.put(hkontroller.CType_TargetHeaterCoolerState,
+			targetHeaterCoolerState2num(h.targetModeEnum.Value))
+	}
+	if sliderDragged(&h.coolingWidget) {
+		h.putDebounced(hkontroller.CType_CoolingThresholdTemperature, targetTempDragDelay, func() interface{} {
+			// one digit after point
+			return float32(math.Floor(float64(h.coolingWidget.Value)*10) / 10)
+		})
+	}
+	if sliderDragged(&h.heatingWidget) {
+		h.putDebounced(hkontroller.CType_HeatingThresholdTemperature, targetTempDragDelay, func() interface{} {
+			return float32(math.Floor(float64(h.heatingWidget.Value)*10) / 10)
+		})
+	}
+	speedMin, speedMax, speedStep := h.valueRange(hkontroller.CType_RotationSpeed, 0, 100, 1)
+	if sliderDragged(&h.speedWidget) {
+		h.putDebounced(hkontroller.CType_RotationSpeed, speedDragDelay, func() interface{} {
+			return roundToStep(float64(h.speedWidget.Value), speedMin, speedStep)
+		})
+	}
+	if h.swing.Changed() {
+		h.put(hkontroller.CType_SwingMode, bool2num(h.swing.Value))
+	}
+
+	ctemp := withUnit("°C")(h.value(hkontroller.CType_CurrentTemperature))
+	cmodeStr := currentHeaterCoolerState2str(h.value(hkontroller.CType_CurrentHeaterCoolerState))
+	tmodeStr := targetHeaterCoolerState2enum(h.value(hkontroller.CType_TargetHeaterCoolerState))
+
+	if h.quick {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(material.Switch(h.th, &h.quickActive, h.label).Layout),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Body1(h.th,
+						fmt.Sprintf("Temp: %s | ", ctemp)).Layout),
+					layout.Rigid(material.Body1(h.th,
+						fmt.Sprintf("Mode: %v", cmodeStr)).Layout),
+				)
+			}),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(h.th, h.label).Layout,
+				material.Switch(h.th, &h.active, h.label).Layout)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(material.Body1(h.th,
+					fmt.Sprintf("Temp: %s | ", ctemp)).Layout),
+				layout.Rigid(material.Body1(h.th,
+					fmt.Sprintf("Mode: %v | ", cmodeStr)).Layout),
+				layout.Rigid(material.Body1(h.th,
+					fmt.Sprintf("Target: %v", tmodeStr)).Layout),
+			)
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "auto", "Auto").Layout),
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "heat", "Heat").Layout),
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "cool", "Cool").Layout),
+			)
+		}),
+	)
+
+	if h.has(hkontroller.CType_CoolingThresholdTemperature) {
+		min, max, _ := h.valueRange(hkontroller.CType_CoolingThresholdTemperature, 10, 35, 0.1)
+		children = append(children,
+			layout.Rigid(material.Body1(h.th,
+				fmt.Sprintf("Cool to: %s", withUnit("°C")(h.value(hkontroller.CType_CoolingThresholdTemperature)))).Layout),
+			layout.Rigid(material.Slider(h.th, &h.coolingWidget, float32(min), float32(max)).Layout))
+	}
+	if h.has(hkontroller.CType_HeatingThresholdTemperature) {
+		min, max, _ := h.valueRange(hkontroller.CType_HeatingThresholdTemperature, 0, 25, 0.1)
+		children = append(children,
+			layout.Rigid(material.Body1(h.th,
+				fmt.Sprintf("Heat to: %s", withUnit("°C")(h.value(hkontroller.CType_HeatingThresholdTemperature)))).Layout),
+			layout.Rigid(material.Slider(h.th, &h.heatingWidget, float32(min), float32(max)).Layout))
+	}
+	if h.has(hkontroller.CType_RotationSpeed) {
+		children = append(children,
+			layout.Rigid(material.Body1(h.th, "Speed").Layout),
+			layout.Rigid(material.Slider(h.th, &h.speedWidget, float32(speedMin), float32(speedMax)).Layout))
+	}
+	if h.has(hkontroller.CType_SwingMode) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+					material.Body1(h.th, "Swing").Layout,
+					material.Switch(h.th, &h.swing, "Swing").Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func currentHeaterCoolerState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "inactive"
+		case 1:
+			valStr = "idle"
+		case 2:
+			valStr = "heating"
+		case 3:
+			valStr = "cooling"
+		}
+	}
+	return valStr
+}
+func targetHeaterCoolerState2enum(v interface{}) string {
+	valStr := ""
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "auto"
+		case 1:
+			valStr = "heat"
+		case 2:
+			valStr = "cool"
+		}
+	}
+	return valStr
+}
+func targetHeaterCoolerState2num(v string) int {
+	valNum := 0
+	switch v {
+	case "auto":
+		valNum = 0
+	case "heat":
+		valNum = 1
+	case "cool":
+		valNum = 2
+	}
+	return valNum
+}
@@ -0,0 +1,232 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const humidityDragDelay = 300 * time.Millisecond
+
+/*
+Required Characteristics
+9.1 Active
+9.34 Current Relative Humidity : 0-100
+9.115 Current Humidifier Dehumidifier State : 0-Inactive 1-Idle 2-Humidifying 3-Dehumidifying
+9.116 Target Humidifier Dehumidifier State : 0-Auto 1-Humidifier 2-Dehumidifier
+
+Optional Characteristics
+9.83 Relative Humidity Dehumidifier Threshold : 0-100
+9.84 Relative Humidity Humidifier Threshold : 0-100
+9.81 Rotation Speed
+9.99 Swing Mode
+9.127 Water Level : 0-100
+9.51 Lock Physical Controls
+*/
+
+type HumidifierDehumidifier struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	active             widget.Bool
+	quickActive        widget.Bool
+	targetModeEnum     widget.Enum
+	humidifierWidget   widget.Float
+	dehumidifierWidget widget.Float
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewHumidifierDehumidifier(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*HumidifierDehumidifier, error) {
+	h := &HumidifierDehumidifier{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	h.serviceChars.onValue = h.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	h.label = label
+
+	err = h.require(
+		hkontroller.CType_Active,
+		hkontroller.CType_CurrentRelativeHumidity,
+		hkontroller.CType_CurrentHumidifierDehumidifierState,
+		hkontroller.CType_TargetHumidifierDehumidifierState)
+	if err != nil {
+		return nil, err
+	}
+	h.optional(
+		hkontroller.CType_RelativeHumidityDehumidifierThreshold,
+		hkontroller.CType_RelativeHumidityHumidifierThreshold,
+		hkontroller.CType_WaterLevel)
+	h.fetch()
+
+	return h, nil
+}
+
+func (h *HumidifierDehumidifier) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		h.active.Value = boolValue(value)
+		h.quickActive.Value = h.active.Value
+	case hkontroller.CType_TargetHumidifierDehumidifierState:
+		h.targetModeEnum.Value = targetHumidifierState2enum(value)
+	case hkontroller.CType_RelativeHumidityHumidifierThreshold:
+		if v, ok := floatValue(value); ok {
+			h.humidifierWidget.Value = float32(v)
+		}
+	case hkontroller.CType_RelativeHumidityDehumidifierThreshold:
+		if v, ok := floatValue(value); ok {
+			h.dehumidifierWidget.Value = float32(v)
+		}
+	}
+}
+
+func (h *HumidifierDehumidifier) QuickAction() {
+	h.active.Value = !h.active.Value
+	h.quickActive.Value = h.active.Value
+	h.put(hkontroller.CType_Active, bool2num(h.active.Value))
+}
+
+func (h *HumidifierDehumidifier) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&h.quickActive, &h.active)
+	if h.active.Changed() {
+		h.put(hkontroller.CType_Active, bool2num(h.active.Value))
+	}
+	for h.targetModeEnum.Changed() {
+		h.put(hkontroller.CType_TargetHumidifierDehumidifierState,
+			targetHumidifierState2num(h.targetModeEnum.Value))
+	}
+	humMin, humMax, humStep := h.valueRange(hkontroller.CType_RelativeHumidityHumidifierThreshold, 0, 100, 1)
+	if sliderDragged(&h.humidifierWidget) {
+		h.putDebounced(hkontroller.CType_RelativeHumidityHumidifierThreshold, humidityDragDelay, func() interface{} {
+			return roundToStep(float64(h.humidifierWidget.Value), humMin, humStep)
+		})
+	}
+	dehumMin, dehumMax, dehumStep := h.valueRange(hkontroller.CType_RelativeHumidityDehumidifierThreshold, 0, 100, 1)
+	if sliderDragged(&h.dehumidifierWidget) {
+		h.putDebounced(hkontroller.CType_RelativeHumidityDehumidifierThreshold, humidityDragDelay, func() interface{} {
+			return roundToStep(float64(h.dehumidifierWidget.Value), dehumMin, dehumStep)
+		})
+	}
+
+	humidity := withUnit("%")(h.value(hkontroller.CType_CurrentRelativeHumidity))
+	cmodeStr := currentHumidifierState2str(h.value(hkontroller.CType_CurrentHumidifierDehumidifierState))
+
+	if h.quick {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(material.Switch(h.th, &h.quickActive, h.label).Layout),
+			layout.Rigid(material.Body1(h.th,
+				fmt.Sprintf("Humidity: %s | %s", humidity, cmodeStr)).Layout),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(h.th, h.label).Layout,
+				material.Switch(h.th, &h.active, h.label).Layout)
+		}),
+		layout.Rigid(material.Body1(h.th,
+			fmt.Sprintf("Humidity: %s | Mode: %s", humidity, cmodeStr)).Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "auto", "Auto").Layout),
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "humidifier", "Humidify").Layout),
+				layout.Rigid(material.RadioButton(h.th,
+					&h.targetModeEnum, "dehumidifier", "Dehumidify").Layout),
+			)
+		}),
+	)
+	if h.has(hkontroller.CType_RelativeHumidityHumidifierThreshold) {
+		children = append(children,
+			layout.Rigid(material.Body1(h.th,
+				fmt.Sprintf("Humidify to: %s", withUnit("%")(h.value(hkontroller.CType_RelativeHumidityHumidifierThreshold)))).Layout),
+			layout.Rigid(material.Slider(h.th, &h.humidifierWidget, float32(humMin), float32(humMax)).Layout))
+	}
+	if h.has(hkontroller.CType_RelativeHumidityDehumidifierThreshold) {
+		children = append(children,
+			layout.Rigid(material.Body1(h.th,
+				fmt.Sprintf("Dehumidify to: %s", withUnit("%")(h.value(hkontroller.CType_RelativeHumidityDehumidifierThreshold)))).Layout),
+			layout.Rigid(material.Slider(h.th, &h.dehumidifierWidget, float32(dehumMin), float32(dehumMax)).Layout))
+	}
+	if h.has(hkontroller.CType_WaterLevel) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(h.th, "Water level").Layout,
+					material.Body2(h.th, withUnit("%")(h.value(hkontroller.CType_WaterLevel))).Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func currentHumidifierState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "inactive"
+		case 1:
+			valStr = "idle"
+		case 2:
+			valStr = "humidifying"
+		case 3:
+			valStr = "dehumidifying"
+		}
+	}
+	return valStr
+}
+func targetHumidifierState2enum(v interface{}) string {
+	valStr := ""
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "auto"
+		case 1:
+			valStr = "humidifier"
+		case 2:
+			valStr = "dehumidifier"
+		}
+	}
+	return valStr
+}
+func targetHumidifierState2num(v string) int {
+	valNum := 0
+	switch v {
+	case "auto":
+		valNum = 0
+	case "humidifier":
+		valNum = 1
+	case "dehumidifier":
+		valNum = 2
+	}
+	return valNum
+}
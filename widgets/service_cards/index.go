@@ -2,9 +2,10 @@ package service_cards
 
 import (
 	"hkapp/application"
+	"strings"
+	"sync"
 
 	"gioui.org/layout"
-	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
 )
 
@@ -21,43 +22,170 @@ func (t withLayoutFunc) Layout(gtx C) D {
 	return t.content(gtx)
 }
 
-func GetWidgetForService(app *application.App,
+// Card is widget displaying single service
+type Card interface {
+	Layout(C) D
+}
+
+// CardFactory constructs card for service
+type CardFactory func(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription) (Card, error)
+
+type registryEntry struct {
+	quick CardFactory
+	full  CardFactory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registryEntry)
+)
+
+// appleUUIDSuffix is base of UUIDs defined by HAP specification
+const appleUUIDSuffix = "-0000-1000-8000-0026BB765291"
+
+// normalizeType brings service type to the form used as registry key:
+// HAP types in short form ("43"), vendor types as upper case UUIDs.
+func normalizeType(t string) string {
+	t = strings.ToUpper(strings.TrimSpace(t))
+	t = strings.TrimSuffix(t, appleUUIDSuffix)
+	if !strings.Contains(t, "-") {
+		t = strings.TrimLeft(t, "0")
+	}
+	return t
+}
+
+// Register sets card factories for service type.
+// Service type may be HAP one or vendor-specific UUID.
+// quick is used for list of accessories, full for accessory page.
+// If quick is nil, full is used for both.
+func Register(stype hkontroller.HapServiceType, quick, full CardFactory) {
+	if quick == nil {
+		quick = full
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[normalizeType(string(stype))] = registryEntry{quick: quick, full: full}
+}
+
+// Unregister removes card factories, so service falls back to generic card
+func Unregister(stype hkontroller.HapServiceType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, normalizeType(string(stype)))
+}
+
+func lookup(stype hkontroller.HapServiceType) (registryEntry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[normalizeType(string(stype))]
+	return e, ok
+}
+
+// GetQuickWidgetForService returns simplified card to display in list of accs
+func GetQuickWidgetForService(app *application.App,
+	acc *hkontroller.Accessory, dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription) (Card, error) {
+	if e, ok := lookup(s.Type); ok && e.quick != nil {
+		return e.quick(app, acc, dev, s)
+	}
+	return NewGenericService(app, acc, dev, s, true)
+}
+
+// GetFullWidgetForService returns card to display on accessory page
+func GetFullWidgetForService(app *application.App,
 	acc *hkontroller.Accessory, dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription) (Card, error) {
+	if e, ok := lookup(s.Type); ok && e.full != nil {
+		return e.full(app, acc, dev, s)
+	}
+	return NewGenericService(app, acc, dev, s, false)
+}
+
+// cardConstructor is signature of built-in cards constructors
+type cardConstructor[T Card] func(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
 	s *hkontroller.ServiceDescription,
-	quickWidget bool,
-) (interface {
-	Layout(C) D
-}, error) {
-
-	label := ""
-	accInfo := acc.GetService(hkontroller.SType_AccessoryInfo)
-	if accInfo != nil {
-		accName := accInfo.GetCharacteristic(hkontroller.CType_Name)
-		if accName != nil {
-			name, ok := accName.Value.(string)
-			if ok {
-				label = name
+	quickWidget bool) (T, error)
+
+// factories makes quick and full factories from built-in constructor
+func factories[T Card](f cardConstructor[T]) (CardFactory, CardFactory) {
+	factory := func(quick bool) CardFactory {
+		return func(app *application.App,
+			acc *hkontroller.Accessory,
+			dev *hkontroller.Device,
+			s *hkontroller.ServiceDescription) (Card, error) {
+			w, err := f(app, acc, dev, s, quick)
+			if err != nil {
+				// avoid returning typed nil in interface
+				return nil, err
 			}
+			return w, nil
 		}
 	}
+	return factory(true), factory(false)
+}
 
-	var w interface {
-		Layout(C) D
-	}
-	var err error
-
-	switch s.Type {
-	case hkontroller.SType_LightBulb:
-		w, err = NewLightBulb(app, acc, dev, quickWidget)
-	case hkontroller.SType_Switch:
-		w, err = NewSwitch(app, acc, dev, quickWidget)
-	case hkontroller.SType_AccessoryInfo:
-		w, err = NewAccessoryInfo(app, acc, dev, quickWidget)
-	case hkontroller.SType_Thermostat:
-		w, err = NewThermostat(app, acc, dev, quickWidget)
-	default:
-		w = material.Body2(app.Theme, label)
+func registerBuiltin[T Card](f cardConstructor[T], stypes ...hkontroller.HapServiceType) {
+	quick, full := factories(f)
+	for _, stype := range stypes {
+		Register(stype, quick, full)
 	}
+}
+
+func init() {
+	// these cards find their service by themselves
+	registerBuiltin(func(app *application.App, acc *hkontroller.Accessory, dev *hkontroller.Device,
+		_ *hkontroller.ServiceDescription, quickWidget bool) (*LightBulb, error) {
+		return NewLightBulb(app, acc, dev, quickWidget)
+	}, hkontroller.SType_LightBulb)
+	registerBuiltin(func(app *application.App, acc *hkontroller.Accessory, dev *hkontroller.Device,
+		_ *hkontroller.ServiceDescription, quickWidget bool) (*Switch, error) {
+		return NewSwitch(app, acc, dev, quickWidget)
+	}, hkontroller.SType_Switch)
+	registerBuiltin(func(app *application.App, acc *hkontroller.Accessory, dev *hkontroller.Device,
+		_ *hkontroller.ServiceDescription, quickWidget bool) (*AccessoryInfo, error) {
+		return NewAccessoryInfo(app, acc, dev, quickWidget)
+	}, hkontroller.SType_AccessoryInfo)
+	registerBuiltin(func(app *application.App, acc *hkontroller.Accessory, dev *hkontroller.Device,
+		_ *hkontroller.ServiceDescription, quickWidget bool) (*Thermostat, error) {
+		return NewThermostat(app, acc, dev, quickWidget)
+	}, hkontroller.SType_Thermostat)
 
-	return w, err
+	registerBuiltin(NewOutlet, hkontroller.SType_Outlet)
+	registerBuiltin(NewWindowCovering, hkontroller.SType_WindowCovering)
+	registerBuiltin(NewLockMechanism, hkontroller.SType_LockMechanism)
+	registerBuiltin(NewGarageDoorOpener, hkontroller.SType_GarageDoorOpener)
+	registerBuiltin(NewSensor,
+		hkontroller.SType_TemperatureSensor,
+		hkontroller.SType_HumiditySensor,
+		hkontroller.SType_LightSensor,
+		hkontroller.SType_CarbonDioxideSensor,
+		hkontroller.SType_AirQualitySensor)
+	registerBuiltin(NewBinarySensor,
+		hkontroller.SType_MotionSensor,
+		hkontroller.SType_ContactSensor,
+		hkontroller.SType_OccupancySensor,
+		hkontroller.SType_LeakSensor,
+		hkontroller.SType_SmokeSensor,
+		hkontroller.SType_CarbonMonoxideSensor)
+	registerBuiltin(NewFan, hkontroller.SType_Fan, hkontroller.SType_FanV2)
+	registerBuiltin(NewHeaterCooler, hkontroller.SType_HeaterCooler)
+	registerBuiltin(NewHumidifierDehumidifier, hkontroller.SType_HumidifierDehumidifier)
+	registerBuiltin(NewAirPurifier, hkontroller.SType_AirPurifier)
+	registerBuiltin(NewValve, hkontroller.SType_Valve)
+	registerBuiltin(NewIrrigationSystem, hkontroller.SType_IrrigationSystem)
+	registerBuiltin(NewFaucet, hkontroller.SType_Faucet)
+	registerBuiltin(NewTelevision, hkontroller.SType_Television)
+	registerBuiltin(NewSpeaker, hkontroller.SType_Speaker, hkontroller.SType_TelevisionSpeaker)
+	registerBuiltin(NewSecuritySystem, hkontroller.SType_SecuritySystem)
+	registerBuiltin(NewStatelessProgrammableSwitch, hkontroller.SType_StatelessProgrammableSwitch)
+	registerBuiltin(NewDoorbell, hkontroller.SType_Doorbell)
+	registerBuiltin(NewDoor, hkontroller.SType_Door)
+	registerBuiltin(NewWindow, hkontroller.SType_Window)
+	registerBuiltin(NewSlat, hkontroller.SType_Slat)
+	registerBuiltin(NewBattery, hkontroller.SType_BatteryService)
 }
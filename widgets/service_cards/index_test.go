@@ -0,0 +1,28 @@
+package service_cards
+
+import "testing"
+
+func TestNormalizeType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"43", "43"},
+		{"0043", "43"},
+		{"8a", "8A"},
+		{" 8A\n", "8A"},
+		{"00000043-0000-1000-8000-0026BB765291", "43"},
+		{"0000008a-0000-1000-8000-0026bb765291", "8A"},
+		{"000000D8-0000-1000-8000-0026BB765291", "D8"},
+		{"00000043-0000-1000-8000-0026BB765292", "00000043-0000-1000-8000-0026BB765292"},
+		{"e863f007-079e-48ff-8f27-9c2605a29f52", "E863F007-079E-48FF-8F27-9C2605A29F52"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := normalizeType(tt.in); got != tt.want {
+				t.Errorf("normalizeType(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"fmt"
 	"hkapp/applayout"
 	"hkapp/application"
+	"hkapp/widgets"
+	"image"
 	"image/color"
 	"math"
 	"time"
 
 	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
 	"gioui.org/unit"
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 )
 
 const brightnessDragDelay = 300 * time.Millisecond
@@ -27,18 +30,16 @@ type LightBulb struct {
 	brightnessWidget widget.Float
 	brightnessValue  float32
 
-	dragTimer *time.Timer
+	colorPicker widgets.HSPicker
+	ctempWidget widget.Float
+	// "hs" or "ct", whichever was set last, to show in swatch
+	colorMode string
 
-	chars map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription
-
-	hapEvents map[hkontroller.HapCharacteristicType]<-chan emitter.Event
-	guiEvents map[hkontroller.HapCharacteristicType]<-chan emitter.Event
+	*serviceChars
 
 	label string
 
-	acc *hkontroller.Accessory
-	dev *hkontroller.Device
-	th  *material.Theme
+	th *material.Theme
 
 	*application.App
 }
@@ -48,188 +49,150 @@ func NewLightBulb(app *application.App,
 	dev *hkontroller.Device,
 	quickWidget bool) (*LightBulb, error) {
 
-	l := &LightBulb{
-		quick:     quickWidget,
-		acc:       acc,
-		dev:       dev,
-		th:        app.Theme,
-		App:       app,
-		chars:     make(map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription),
-		hapEvents: make(map[hkontroller.HapCharacteristicType]<-chan emitter.Event),
-		guiEvents: make(map[hkontroller.HapCharacteristicType]<-chan emitter.Event),
-	}
-
-	infoS := acc.GetService(hkontroller.SType_AccessoryInfo)
-	if infoS == nil {
-		return nil, errors.New("cannot get AccessoryInfo service")
-	}
-	labelC := infoS.GetCharacteristic(hkontroller.CType_Name)
-	if labelC == nil {
-		return nil, errors.New("cannot get characteristic Name")
-	}
-	label, ok := labelC.Value.(string)
-	if !ok {
-		return nil, errors.New("cannot extract accessory name")
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
 	}
-	l.label = label
 
 	lightbS := acc.GetService(hkontroller.SType_LightBulb)
 	if lightbS == nil {
 		return nil, errors.New("cannot find LightBulb service")
 	}
-	onC := lightbS.GetCharacteristic(hkontroller.CType_On)
-	if onC == nil {
-		return nil, errors.New("cannot find characteristic On")
-	} else {
-		l.chars[hkontroller.CType_On] = onC
-		withValC, err := dev.GetCharacteristic(acc.Id, onC.Iid)
-		if err == nil {
-			l.onValue(withValC.Value, hkontroller.CType_On)
-		}
-	}
 
-	// optional characteristics
-	brightnessC := lightbS.GetCharacteristic(hkontroller.CType_Brightness)
-	if brightnessC != nil {
-		l.chars[hkontroller.CType_Brightness] = brightnessC
-		withValC, err := dev.GetCharacteristic(acc.Id, brightnessC.Iid)
-		if err == nil {
-			l.onValue(withValC.Value, hkontroller.CType_Brightness)
-		}
-	}
-	hueC := lightbS.GetCharacteristic(hkontroller.CType_Hue)
-	if hueC != nil {
-		l.chars[hkontroller.CType_Hue] = hueC
-	}
-	satC := lightbS.GetCharacteristic(hkontroller.CType_Saturation)
-	if hueC != nil {
-		l.chars[hkontroller.CType_Saturation] = satC
+	l := &LightBulb{
+		quick:        quickWidget,
+		label:        label,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, lightbS),
 	}
-	ctempC := lightbS.GetCharacteristic(hkontroller.CType_ColorTemperature)
-	if hueC != nil {
-		l.chars[hkontroller.CType_ColorTemperature] = ctempC
+	l.serviceChars.onValue = l.onValue
+
+	if err := l.require(hkontroller.CType_On); err != nil {
+		return nil, err
 	}
+	l.optional(hkontroller.CType_Brightness,
+		hkontroller.CType_Hue,
+		hkontroller.CType_Saturation,
+		hkontroller.CType_ColorTemperature)
+	l.fetch()
 
 	return l, nil
 }
 
-func (l *LightBulb) onValue(value interface{}, ctype hkontroller.HapCharacteristicType) {
+func (l *LightBulb) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
 	if ctype == hkontroller.CType_On {
-		onValue, ok := value.(bool)
-		if !ok {
-			var onValInt float64
-			onValInt, ok = value.(float64)
-			if ok {
-				onValue = onValInt > 0
-			}
-		}
-		l.on.Value = onValue
-		l.quickOn.Value = onValue
+		l.on.Value = boolValue(value)
+		l.quickOn.Value = l.on.Value
 	}
 	if ctype == hkontroller.CType_Brightness {
-		if valInt, ok := value.(int); ok {
-			l.brightnessWidget.Value = float32(valInt)
-		} else if valF32, ok := value.(float32); ok {
-			l.brightnessWidget.Value = valF32
-		} else if valF64, ok := value.(float64); ok {
-			l.brightnessWidget.Value = float32(valF64)
+		if v, ok := floatValue(value); ok {
+			l.brightnessWidget.Value = float32(v)
 		}
 	}
-}
-
-func (l *LightBulb) SubscribeToEvents() {
-
-	var err error
-	var ev <-chan emitter.Event
-	devId := l.dev.Name
-	aid := l.acc.Id
-	onEvent := func(e emitter.Event, ctype hkontroller.HapCharacteristicType) {
-		value := e.Args[2]
-		l.onValue(value, ctype)
-		l.App.Window.Invalidate()
-	}
-	for ctype, cdescr := range l.chars {
-		iid := cdescr.Iid
-		ev, err = l.dev.SubscribeToEvents(aid, iid)
-		if err != nil {
-			fmt.Println("err subscribing: ", cdescr.Type.String(), err)
-			continue
+	if ctype == hkontroller.CType_Hue {
+		if v, ok := floatValue(value); ok {
+			l.colorPicker.Hue = float32(v)
+			l.colorMode = "hs"
 		}
-		go func(evs <-chan emitter.Event, ct hkontroller.HapCharacteristicType) {
-			for e := range evs {
-				onEvent(e, ct)
-			}
-		}(ev, ctype)
-		l.hapEvents[ctype] = ev
-
-		ev = l.App.OnValueChange(devId, aid, iid)
-		l.guiEvents[ctype] = ev
-		go func(evs <-chan emitter.Event, ct hkontroller.HapCharacteristicType) {
-			for e := range evs {
-				onEvent(e, ct)
-			}
-		}(ev, ctype)
 	}
-}
-func (l *LightBulb) UnsubscribeFromEvents() {
-	aid := l.acc.Id
-	devId := l.dev.Name
-	for ctype, ee := range l.hapEvents {
-		iid := l.chars[ctype].Iid
-		err := l.dev.UnsubscribeFromEvents(aid, iid, ee)
-		if err != nil {
-			continue
+	if ctype == hkontroller.CType_Saturation {
+		if v, ok := floatValue(value); ok {
+			l.colorPicker.Saturation = float32(v)
+			l.colorMode = "hs"
 		}
-		delete(l.hapEvents, ctype)
 	}
-	for ctype, ee := range l.guiEvents {
-		iid := l.chars[ctype].Iid
-		l.App.OffValueChange(devId, aid, iid, ee)
-		delete(l.hapEvents, ctype)
+	if ctype == hkontroller.CType_ColorTemperature {
+		if v, ok := floatValue(value); ok {
+			l.ctempWidget.Value = float32(v)
+			l.colorMode = "ct"
+		}
 	}
-	return
 }
 
 func (l *LightBulb) QuickAction() {
 	l.on.Value = !l.on.Value
+	l.quickOn.Value = l.on.Value
 	l.onBoolValueChanged()
 }
 
 func (l *LightBulb) onBoolValueChanged() error {
+	return l.put(hkontroller.CType_On, l.on.Value)
+}
 
-	chr := l.chars[hkontroller.CType_On]
-	go func() {
-		err := l.dev.PutCharacteristic(l.acc.Id, chr.Iid, l.on.Value)
-		if err != nil {
-			return
+func (l *LightBulb) onBrightnessSlider() {
+	l.putDebounced(hkontroller.CType_Brightness, brightnessDragDelay, func() interface{} {
+		return math.Floor(float64(l.brightnessWidget.Value))
+	})
+}
+
+// onColorPicker writes hue and saturation together when picker stays still for a while
+func (l *LightBulb) onColorPicker() {
+	l.colorMode = "hs"
+	l.putManyDebounced(hkontroller.CType_Hue, brightnessDragDelay, func() map[hkontroller.HapCharacteristicType]interface{} {
+		values := map[hkontroller.HapCharacteristicType]interface{}{
+			hkontroller.CType_Hue: math.Round(float64(l.colorPicker.Hue)),
+		}
+		if l.has(hkontroller.CType_Saturation) {
+			values[hkontroller.CType_Saturation] = math.Round(float64(l.colorPicker.Saturation))
 		}
+		return values
+	})
+}
 
-		l.App.EmitValueChange(l.dev.Name, l.acc.Id, chr.Iid, l.on.Value)
-	}()
+// onColorTemperatureSlider writes color temperature in mireds
+func (l *LightBulb) onColorTemperatureSlider() {
+	l.colorMode = "ct"
+	l.putDebounced(hkontroller.CType_ColorTemperature, brightnessDragDelay, func() interface{} {
+		return math.Round(float64(l.ctempWidget.Value))
+	})
+}
 
-	return nil
+// ctempRange returns min and max mireds supported by lamp
+func (l *LightBulb) ctempRange() (float32, float32) {
+	min, max, _ := l.valueRange(hkontroller.CType_ColorTemperature, 140, 500, 1)
+	return float32(min), float32(max)
 }
-func (l *LightBulb) onBrightnessSlider() error {
-	if l.dragTimer != nil {
-		l.dragTimer.Stop()
+
+// currentColor returns color of light, if lamp supports colors
+func (l *LightBulb) currentColor() (color.NRGBA, bool) {
+	_, hasHue := l.chars[hkontroller.CType_Hue]
+	_, hasCtemp := l.chars[hkontroller.CType_ColorTemperature]
+	switch {
+	case hasHue && (l.colorMode == "hs" || !hasCtemp):
+		return widgets.HSVColor(l.colorPicker.Hue, l.colorPicker.Saturation, 100), true
+	case hasCtemp:
+		return mired2color(l.ctempWidget.Value), true
 	}
-	l.dragTimer = time.AfterFunc(brightnessDragDelay, func() {
-		chr := l.chars[hkontroller.CType_Brightness]
-		val := math.Floor(float64(l.brightnessWidget.Value))
-		go func() {
-			l.dev.PutCharacteristic(l.acc.Id, chr.Iid, val)
-			l.App.EmitValueChange(l.dev.Name, l.acc.Id, chr.Iid, l.brightnessWidget.Value)
-		}()
-	})
+	return color.NRGBA{}, false
+}
 
-	return nil
+func (l *LightBulb) layoutSwatch(gtx C) D {
+	c, ok := l.currentColor()
+	if !ok {
+		return D{}
+	}
+	if !l.on.Value {
+		c.A = 64
+	}
+	size := gtx.Dp(unit.Dp(16))
+	rect := clip.UniformRRect(image.Rectangle{Max: image.Pt(size, size)}, gtx.Dp(unit.Dp(3)))
+	paint.FillShape(gtx.Ops, c, rect.Op(gtx.Ops))
+	return D{Size: image.Pt(size, size)}
 }
 
 func (l *LightBulb) Layout(gtx C) D {
 
-	if l.brightnessWidget.Changed() {
+	mirrorQuickSwitch(&l.quickOn, &l.on)
+	if sliderDragged(&l.brightnessWidget) {
 		l.onBrightnessSlider()
 	}
+	if l.colorPicker.Changed() {
+		l.onColorPicker()
+	}
+	if sliderDragged(&l.ctempWidget) {
+		l.onColorTemperatureSlider()
+	}
 
 	if l.on.Changed() {
 		l.onBoolValueChanged()
@@ -251,6 +214,11 @@ func (l *LightBulb) Layout(gtx C) D {
 				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 					return material.Switch(l.th, &l.quickOn, l.label).Layout(gtx)
 				}))
+			if _, ok := l.currentColor(); ok {
+				children = append(children,
+					layout.Rigid(layout.Spacer{Width: unit.Dp(6)}.Layout),
+					layout.Rigid(l.layoutSwatch))
+			}
 			if _, ok := l.chars[hkontroller.CType_Brightness]; ok {
 				brStr := fmt.Sprintf(" B: %d",
 					int(math.Floor(float64(l.brightnessWidget.Value))))
@@ -279,16 +247,26 @@ func (l *LightBulb) Layout(gtx C) D {
 			}
 
 			if _, ok := l.chars[hkontroller.CType_Hue]; ok {
+				colorStr := fmt.Sprintf("Color: hue %d, saturation %d%%",
+					int(l.colorPicker.Hue), int(l.colorPicker.Saturation))
 				children = append(children,
-					layout.Rigid(material.Body1(l.th, "supports Hue").Layout))
-			}
-			if _, ok := l.chars[hkontroller.CType_Saturation]; ok {
-				children = append(children,
-					layout.Rigid(material.Body1(l.th, "supports Saturation").Layout))
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+							layout.Rigid(material.Body1(l.th, colorStr).Layout),
+							layout.Rigid(layout.Spacer{Width: unit.Dp(6)}.Layout),
+							layout.Rigid(l.layoutSwatch))
+					}),
+					layout.Rigid(func(gtx C) D {
+						return l.colorPicker.Layout(gtx, unit.Dp(120))
+					}))
 			}
 			if _, ok := l.chars[hkontroller.CType_ColorTemperature]; ok {
+				min, max := l.ctempRange()
+				ctempStr := fmt.Sprintf("Color temperature: %d mired (%dK)",
+					int(l.ctempWidget.Value), mired2kelvin(l.ctempWidget.Value))
 				children = append(children,
-					layout.Rigid(material.Body1(l.th, "supports ColorTemperature").Layout))
+					layout.Rigid(material.Body1(l.th, ctempStr).Layout),
+					layout.Rigid(material.Slider(l.th, &l.ctempWidget, min, max).Layout))
 			}
 
 			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
@@ -297,3 +275,37 @@ func (l *LightBulb) Layout(gtx C) D {
 		}
 	})
 }
+
+// util
+// ------------------
+func mired2kelvin(m float32) int {
+	if m <= 0 {
+		return 0
+	}
+	return int(1000000 / m)
+}
+
+// mired2color approximates color of white light with given temperature
+func mired2color(m float32) color.NRGBA {
+	t := float64(mired2kelvin(m)) / 100
+	clampC := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, v)))
+	}
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	return color.NRGBA{R: clampC(r), G: clampC(g), B: clampC(b), A: 255}
+}
@@ -0,0 +1,166 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+9.52 Lock Current State : 0-Unsecured 1-Secured 2-Jammed 3-Unknown
+9.56 Lock Target State : 0-Unsecured 1-Secured
+*/
+
+const (
+	lockUnsecured = 0
+	lockSecured   = 1
+)
+
+type LockMechanism struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	lockBtn   widget.Clickable
+	unlockBtn widget.Clickable
+
+	// unlocking goes through confirmation
+	confirmUnlock    bool
+	confirmUnlockBtn widget.Clickable
+	cancelUnlockBtn  widget.Clickable
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewLockMechanism(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*LockMechanism, error) {
+	l := &LockMechanism{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	l.label = label
+
+	err = l.require(
+		hkontroller.CType_LockCurrentState,
+		hkontroller.CType_LockTargetState)
+	if err != nil {
+		return nil, err
+	}
+	l.fetch()
+
+	return l, nil
+}
+
+// QuickAction only locks. Unlocking is available on accessory page.
+func (l *LockMechanism) QuickAction() {
+	l.put(hkontroller.CType_LockTargetState, lockSecured)
+}
+
+func (l *LockMechanism) Layout(gtx C) D {
+
+	for l.lockBtn.Clicked() {
+		l.confirmUnlock = false
+		l.put(hkontroller.CType_LockTargetState, lockSecured)
+	}
+	for l.unlockBtn.Clicked() {
+		l.confirmUnlock = true
+	}
+	for l.cancelUnlockBtn.Clicked() {
+		l.confirmUnlock = false
+	}
+	for l.confirmUnlockBtn.Clicked() {
+		l.confirmUnlock = false
+		l.put(hkontroller.CType_LockTargetState, lockUnsecured)
+	}
+
+	currentStr := lockCurrentState2str(l.value(hkontroller.CType_LockCurrentState))
+	targetStr := lockTargetState2str(l.value(hkontroller.CType_LockTargetState))
+
+	state := func(gtx C) D {
+		lbl := material.Body1(l.th, fmt.Sprintf("State: %s | Target: %s", currentStr, targetStr))
+		if cs, ok := intValue(l.value(hkontroller.CType_LockCurrentState)); ok && cs > lockSecured {
+			// jammed or unknown
+			lbl.Color = color.NRGBA{R: 200, A: 255}
+		}
+		return lbl.Layout(gtx)
+	}
+
+	if l.quick {
+		return state(gtx)
+	}
+
+	var children []layout.FlexChild
+	children = append(children, layout.Rigid(state))
+	if l.confirmUnlock {
+		children = append(children,
+			layout.Rigid(material.Body1(l.th, fmt.Sprintf("Unlock %s?", l.label)).Layout),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Button(l.th, &l.confirmUnlockBtn, "Unlock").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(l.th, &l.cancelUnlockBtn, "Cancel").Layout),
+				)
+			}))
+	} else {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Button(l.th, &l.lockBtn, "Lock").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(l.th, &l.unlockBtn, "Unlock").Layout),
+				)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func lockCurrentState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "unlocked"
+		case 1:
+			valStr = "locked"
+		case 2:
+			valStr = "jammed"
+		}
+	}
+	return valStr
+}
+func lockTargetState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "unlocked"
+		case 1:
+			valStr = "locked"
+		}
+	}
+	return valStr
+}
@@ -0,0 +1,123 @@
+package service_cards
+
+import (
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+9.70 On
+9.71 Outlet In Use
+*/
+
+type Outlet struct {
+	quick bool // simplified version to display in list of accs
+
+	on      widget.Bool
+	quickOn widget.Bool
+	inUse   bool
+
+	label string
+
+	*serviceChars
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewOutlet(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Outlet, error) {
+	o := &Outlet{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	o.serviceChars.onValue = o.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	o.label = label
+
+	err = o.require(hkontroller.CType_On, hkontroller.CType_OutletInUse)
+	if err != nil {
+		return nil, err
+	}
+	o.fetch()
+
+	return o, nil
+}
+
+func (o *Outlet) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_On:
+		o.on.Value = boolValue(value)
+		o.quickOn.Value = o.on.Value
+	case hkontroller.CType_OutletInUse:
+		o.inUse = boolValue(value)
+	}
+}
+
+func (o *Outlet) QuickAction() {
+	o.on.Value = !o.on.Value
+	o.quickOn.Value = o.on.Value
+	o.put(hkontroller.CType_On, o.on.Value)
+}
+
+func (o *Outlet) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&o.quickOn, &o.on)
+	if o.on.Changed() {
+		o.put(hkontroller.CType_On, o.on.Value)
+	}
+
+	inUseStr := "not in use"
+	if o.inUse {
+		inUseStr = "in use"
+	}
+
+	return widget.Border{
+		Color: color.NRGBA{
+			R: 0,
+			G: 0,
+			B: 0,
+			A: 0,
+		},
+		Width:        unit.Dp(1),
+		CornerRadius: unit.Dp(1),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		if o.quick {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(material.Switch(o.th, &o.quickOn, o.label).Layout),
+				layout.Rigid(material.Body1(o.th, " "+inUseStr).Layout),
+			)
+		} else {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+						material.Body1(o.th, o.label).Layout,
+						material.Switch(o.th, &o.on, o.label).Layout)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+						material.Body2(o.th, "Outlet").Layout,
+						material.Body2(o.th, inUseStr).Layout)
+				}),
+			)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+	"math"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const positionDragDelay = 300 * time.Millisecond
+
+// tiltControl is slider for one pair of current/target tilt angles
+type tiltControl struct {
+	name    string
+	current hkontroller.HapCharacteristicType
+	target  hkontroller.HapCharacteristicType
+	fw      widget.Float
+}
+
+// positionControl is shared part of cards for movable-position services:
+// WindowCovering, Door, Window and Slat.
+// It works with any subset of position and tilt characteristics,
+// so it has to be created after require/optional and before fetch.
+type positionControl struct {
+	*serviceChars
+
+	targetPosWidget widget.Float
+	tilts           []*tiltControl
+
+	th *material.Theme
+}
+
+func newPositionControl(sc *serviceChars, th *material.Theme) *positionControl {
+	p := &positionControl{
+		serviceChars: sc,
+		th:           th,
+	}
+	allTilts := []*tiltControl{
+		{name: "Horizontal tilt",
+			current: hkontroller.CType_CurrentHorizontalTiltAngle,
+			target:  hkontroller.CType_TargetHorizontalTiltAngle},
+		{name: "Vertical tilt",
+			current: hkontroller.CType_CurrentVerticalTiltAngle,
+			target:  hkontroller.CType_TargetVerticalTiltAngle},
+		{name: "Tilt",
+			current: hkontroller.CType_CurrentTiltAngle,
+			target:  hkontroller.CType_TargetTiltAngle},
+	}
+	for _, t := range allTilts {
+		if sc.has(t.current) || sc.has(t.target) {
+			p.tilts = append(p.tilts, t)
+		}
+	}
+	return p
+}
+
+// onValue updates sliders, should be called from card's onValue
+func (p *positionControl) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	v, ok := floatValue(value)
+	if !ok {
+		return
+	}
+	if ctype == hkontroller.CType_TargetPosition {
+		p.targetPosWidget.Value = float32(v)
+		return
+	}
+	for _, t := range p.tilts {
+		if ctype == t.target {
+			t.fw.Value = float32(v)
+		}
+	}
+}
+
+// quickAction fully opens, or closes if it is open or opening
+func (p *positionControl) quickAction() {
+	if !p.has(hkontroller.CType_TargetPosition) {
+		return
+	}
+	min, max, _ := p.valueRange(hkontroller.CType_TargetPosition, 0, 100, 1)
+	target := max
+	if v, ok := floatValue(p.value(hkontroller.CType_TargetPosition)); ok && v > min {
+		target = min
+	}
+	p.targetPosWidget.Value = float32(target)
+	p.put(hkontroller.CType_TargetPosition, target)
+}
+
+// onSlider writes value only when slider stays still for a while
+func (p *positionControl) onSlider(ctype hkontroller.HapCharacteristicType, fw *widget.Float) {
+	p.putDebounced(ctype, positionDragDelay, func() interface{} {
+		return math.Round(float64(fw.Value))
+	})
+}
+
+// update processes slider changes, should be called at start of card's Layout
+func (p *positionControl) update() {
+	if sliderDragged(&p.targetPosWidget) {
+		p.onSlider(hkontroller.CType_TargetPosition, &p.targetPosWidget)
+	}
+	for _, t := range p.tilts {
+		if sliderDragged(&t.fw) {
+			p.onSlider(t.target, &t.fw)
+		}
+	}
+}
+
+// layout returns status rows and, for full version, sliders
+func (p *positionControl) layout(quick bool) []layout.FlexChild {
+	var children []layout.FlexChild
+
+	if p.has(hkontroller.CType_CurrentPosition) {
+		cpos := valueStr(p.value(hkontroller.CType_CurrentPosition))
+		tpos := valueStr(p.value(hkontroller.CType_TargetPosition))
+		stateStr := positionState2str(p.value(hkontroller.CType_PositionState))
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Body1(p.th,
+						fmt.Sprintf("Position: %s%% | ", cpos)).Layout),
+					layout.Rigid(material.Body1(p.th,
+						fmt.Sprintf("Target: %s%% | ", tpos)).Layout),
+					layout.Rigid(material.Body1(p.th, stateStr).Layout),
+				)
+			}))
+	}
+	if boolValue(p.value(hkontroller.CType_ObstructionDetected)) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body1(p.th, "Obstruction detected!")
+				lbl.Color = color.NRGBA{R: 200, A: 255}
+				return lbl.Layout(gtx)
+			}))
+	}
+
+	if quick {
+		return children
+	}
+
+	if p.has(hkontroller.CType_TargetPosition) {
+		min, max, _ := p.valueRange(hkontroller.CType_TargetPosition, 0, 100, 1)
+		children = append(children,
+			layout.Rigid(material.Body1(p.th, "Target position").Layout),
+			layout.Rigid(material.Slider(p.th, &p.targetPosWidget, float32(min), float32(max)).Layout))
+	}
+	for _, tilt := range p.tilts {
+		tilt := tilt
+		if p.has(tilt.current) {
+			children = append(children,
+				layout.Rigid(func(gtx C) D {
+					return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+						material.Body2(p.th, tilt.name).Layout,
+						material.Body2(p.th, fmt.Sprintf("%s°", valueStr(p.value(tilt.current)))).Layout)
+				}))
+		}
+		if p.has(tilt.target) {
+			min, max, _ := p.valueRange(tilt.target, -90, 90, 1)
+			children = append(children,
+				layout.Rigid(material.Slider(p.th, &tilt.fw, float32(min), float32(max)).Layout))
+		}
+	}
+
+	return children
+}
+
+// util
+// ------------------
+func positionState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "closing"
+		case 1:
+			valStr = "opening"
+		case 2:
+			valStr = "stopped"
+		}
+	}
+	return valStr
+}
@@ -0,0 +1,203 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"sync"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+// number of presses kept in history
+const switchHistorySize = 10
+
+/*
+Stateless Programmable Switch
+Required Characteristics
+Programmable Switch Event : 0-Single Press 1-Double Press 2-Long Press
+
+Optional Characteristics
+Name
+Service Label Index : button number for multi-button remotes
+
+Doorbell
+Required Characteristics
+Programmable Switch Event : 0-Single Press only
+
+Optional Characteristics
+Name
+Volume
+Brightness
+*/
+
+// switchPress is single recorded ProgrammableSwitchEvent
+type switchPress struct {
+	at   time.Time
+	kind int
+}
+
+// ProgrammableSwitch is card for StatelessProgrammableSwitch and Doorbell.
+// These services have no state, so card only records received events.
+type ProgrammableSwitch struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	doorbell bool
+
+	*serviceChars
+
+	historyMu sync.Mutex
+	history   []switchPress
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewStatelessProgrammableSwitch(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ProgrammableSwitch, error) {
+	return newProgrammableSwitch(app, acc, dev, s, quickWidget)
+}
+
+func NewDoorbell(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ProgrammableSwitch, error) {
+	p, err := newProgrammableSwitch(app, acc, dev, s, quickWidget)
+	if err != nil {
+		return nil, err
+	}
+	p.doorbell = true
+	return p, nil
+}
+
+func newProgrammableSwitch(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ProgrammableSwitch, error) {
+	p := &ProgrammableSwitch{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	p.serviceChars.onValue = p.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	p.label = label
+
+	err = p.require(hkontroller.CType_ProgrammableSwitchEvent)
+	if err != nil {
+		return nil, err
+	}
+	p.optional(
+		hkontroller.CType_Name,
+		hkontroller.CType_ServiceLabelIndex)
+
+	// values are not fetched: reading event characteristic returns null
+	// or last event, which would be recorded as new press
+	if name, ok := p.value(hkontroller.CType_Name).(string); ok && name != "" {
+		p.label = name
+	} else if idx, ok := intValue(p.value(hkontroller.CType_ServiceLabelIndex)); ok {
+		p.label = fmt.Sprintf("Button %d", idx)
+	}
+
+	return p, nil
+}
+
+func (p *ProgrammableSwitch) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	// cached and polled values are not presses
+	if ctype != hkontroller.CType_ProgrammableSwitchEvent || source != application.SourceHAP {
+		return
+	}
+	kind, ok := intValue(value)
+	if !ok {
+		return
+	}
+	press := switchPress{at: time.Now(), kind: kind}
+
+	p.historyMu.Lock()
+	p.history = append([]switchPress{press}, p.history...)
+	if len(p.history) > switchHistorySize {
+		p.history = p.history[:switchHistorySize]
+	}
+	p.historyMu.Unlock()
+
+	if p.doorbell {
+		tag := alertTag{dev: p.dev.Name, aid: p.acc.Id, iid: p.chars[ctype].Iid}
+		p.App.Router.ShowBanner(tag,
+			fmt.Sprintf("%s: doorbell rang at %s", p.label, press.at.Format("15:04:05")))
+		p.App.Window.Invalidate()
+	}
+}
+
+func (p *ProgrammableSwitch) Layout(gtx C) D {
+	p.historyMu.Lock()
+	history := make([]switchPress, len(p.history))
+	copy(history, p.history)
+	p.historyMu.Unlock()
+
+	pressStr := func(sp switchPress) string {
+		if p.doorbell {
+			return "ring"
+		}
+		return switchEvent2str(sp.kind)
+	}
+
+	lastStr := "no events"
+	if len(history) > 0 {
+		lastStr = fmt.Sprintf("%s at %s", pressStr(history[0]), history[0].at.Format("15:04:05"))
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body1(p.th, p.label).Layout,
+				material.Body1(p.th, lastStr).Layout)
+		}))
+
+	if p.quick || len(history) < 2 {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	for _, sp := range history[1:] {
+		sp := sp
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(p.th, sp.at.Format("15:04:05")).Layout,
+					material.Body2(p.th, pressStr(sp)).Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func switchEvent2str(v int) string {
+	valStr := "unknown"
+	switch v {
+	case 0:
+		valStr = "single press"
+	case 1:
+		valStr = "double press"
+	case 2:
+		valStr = "long press"
+	}
+	return valStr
+}
@@ -0,0 +1,429 @@
+package service_cards
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Security System
+Required Characteristics
+9.88 Security System Current State : 0-Stay Arm 1-Away Arm 2-Night Arm 3-Disarmed 4-Alarm Triggered
+9.89 Security System Target State : 0-Stay Arm 1-Away Arm 2-Night Arm 3-Disarm
+
+Optional Characteristics
+9.62 Name
+9.87 Security System Alarm Type : 0-No Alarm 1-Unknown
+9.97 Status Fault
+9.100 Status Tampered
+*/
+
+const (
+	securityDisarm    = 3
+	securityTriggered = 4
+)
+
+// Disarm PIN only guards buttons of this app from accidental touches,
+// it does not protect accessory: any paired controller can disarm it.
+// PIN is stored in accessory metadata as "salt$hash", hash is PBKDF2-HMAC-SHA256.
+const (
+	securityPinKey    = "security_pin"
+	securityPinRounds = 100000
+)
+
+type SecuritySystem struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	triggered bool
+
+	targetEnum widget.Enum
+
+	// disarming goes through confirmation and PIN, if set
+	confirmDisarm    bool
+	confirmDisarmBtn widget.Clickable
+	cancelDisarmBtn  widget.Clickable
+	pinInput         widget.Editor
+	pinError         string
+
+	newPinInput widget.Editor
+	setPinBtn   widget.Clickable
+	clearPinBtn widget.Clickable
+	pinHash     string
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewSecuritySystem(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*SecuritySystem, error) {
+	ss := &SecuritySystem{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+		pinInput:     widget.Editor{SingleLine: true, Submit: true, Mask: '*'},
+		newPinInput:  widget.Editor{SingleLine: true, Mask: '*'},
+	}
+	ss.serviceChars.onValue = ss.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	ss.label = label
+
+	err = ss.require(
+		hkontroller.CType_SecuritySystemCurrentState,
+		hkontroller.CType_SecuritySystemTargetState)
+	if err != nil {
+		return nil, err
+	}
+	ss.optional(
+		hkontroller.CType_SecuritySystemAlarmType,
+		hkontroller.CType_StatusFault,
+		hkontroller.CType_StatusTampered)
+
+	if meta, err := app.Load(dev.Name, acc.Id); err == nil {
+		if p, ok := meta[securityPinKey]; ok && len(p) > 0 {
+			ss.pinHash = p[0]
+		}
+	}
+
+	ss.fetch()
+
+	return ss, nil
+}
+
+func (ss *SecuritySystem) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_SecuritySystemTargetState:
+		ss.targetEnum.Value = securityTargetState2enum(value)
+	case hkontroller.CType_SecuritySystemCurrentState:
+		wasTriggered := ss.triggered
+		v, _ := intValue(value)
+		ss.triggered = v == securityTriggered
+		tag := alertTag{dev: ss.dev.Name, aid: ss.acc.Id, iid: ss.chars[ctype].Iid}
+		if ss.triggered && !wasTriggered {
+			ss.App.Router.ShowBanner(tag, fmt.Sprintf("%s: alarm triggered", ss.label))
+			ss.App.Window.Invalidate()
+		} else if !ss.triggered && wasTriggered {
+			ss.App.Router.DismissBanner(tag)
+			ss.App.Window.Invalidate()
+		}
+	}
+}
+
+// Alert reports whether alarm is triggered
+func (ss *SecuritySystem) Alert() bool {
+	return ss.triggered
+}
+
+// pbkdf2 derives one block of PBKDF2-HMAC-SHA256 key
+func pbkdf2(password, salt []byte, rounds int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	var block [4]byte
+	binary.BigEndian.PutUint32(block[:], 1)
+	mac.Write(block[:])
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < rounds; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// pinHashFor returns salted hash to store PIN in accessory metadata
+func pinHashFor(pin string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(pin), salt, securityPinRounds)
+	return hex.EncodeToString(salt) + "$" + hex.EncodeToString(key), nil
+}
+
+// pinMatches reports whether pin hashes to stored "salt$hash"
+func pinMatches(pin, stored string) bool {
+	saltHex, keyHex, ok := strings.Cut(stored, "$")
+	if !ok {
+		return false
+	}
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(pbkdf2([]byte(pin), salt, securityPinRounds), key) == 1
+}
+
+// checkPin verifies entered PIN, any PIN is accepted if not set
+func (ss *SecuritySystem) checkPin() bool {
+	if ss.pinHash == "" {
+		return true
+	}
+	ok := pinMatches(ss.pinInput.Text(), ss.pinHash)
+	ss.pinInput.SetText("")
+	if !ok {
+		ss.pinError = "wrong PIN"
+	}
+	return ok
+}
+
+func (ss *SecuritySystem) savePin(hash string) {
+	meta := make(map[string][]string)
+	if hash == "" {
+		meta[securityPinKey] = []string{}
+	} else {
+		meta[securityPinKey] = []string{hash}
+	}
+	err := ss.App.Save(ss.dev.Name, ss.acc.Id, meta)
+	if err != nil {
+		ss.pinError = err.Error()
+		return
+	}
+	ss.pinHash = hash
+	ss.pinError = ""
+}
+
+func (ss *SecuritySystem) disarm() {
+	if !ss.checkPin() {
+		return
+	}
+	ss.confirmDisarm = false
+	ss.pinError = ""
+	ss.put(hkontroller.CType_SecuritySystemTargetState, securityDisarm)
+}
+
+func (ss *SecuritySystem) Layout(gtx C) D {
+
+	for ss.targetEnum.Changed() {
+		if ss.targetEnum.Value == "disarm" {
+			// keep previous target until confirmed
+			ss.targetEnum.Value = securityTargetState2enum(ss.value(hkontroller.CType_SecuritySystemTargetState))
+			ss.confirmDisarm = true
+			continue
+		}
+		ss.confirmDisarm = false
+		ss.put(hkontroller.CType_SecuritySystemTargetState,
+			securityTargetState2num(ss.targetEnum.Value))
+	}
+	for ss.cancelDisarmBtn.Clicked() {
+		ss.confirmDisarm = false
+		ss.pinError = ""
+		ss.pinInput.SetText("")
+	}
+	for ss.confirmDisarmBtn.Clicked() {
+		ss.disarm()
+	}
+	for _, e := range ss.pinInput.Events() {
+		if _, ok := e.(widget.SubmitEvent); ok && ss.confirmDisarm {
+			ss.disarm()
+		}
+	}
+	for ss.setPinBtn.Clicked() {
+		pin := ss.newPinInput.Text()
+		ss.newPinInput.SetText("")
+		if pin != "" && ss.checkPin() {
+			hash, err := pinHashFor(pin)
+			if err != nil {
+				ss.pinError = err.Error()
+				continue
+			}
+			ss.savePin(hash)
+		}
+	}
+	for ss.clearPinBtn.Clicked() {
+		if ss.checkPin() {
+			ss.savePin("")
+		}
+	}
+
+	currentStr := securityCurrentState2str(ss.value(hkontroller.CType_SecuritySystemCurrentState))
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			state := material.Body1(ss.th, currentStr)
+			if ss.triggered {
+				state.Color = alertColor
+			}
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body1(ss.th, "Security").Layout,
+				state.Layout)
+		}))
+	if badges := statusBadges(ss.th, ss.serviceChars); len(badges) > 0 {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx, badges...)
+			}))
+	}
+
+	if ss.quick {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	}
+
+	if ss.has(hkontroller.CType_SecuritySystemAlarmType) {
+		alarmStr := "no alarm"
+		if v, ok := intValue(ss.value(hkontroller.CType_SecuritySystemAlarmType)); ok && v != 0 {
+			alarmStr = "unknown"
+		}
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+					material.Body2(ss.th, "Alarm type").Layout,
+					material.Body2(ss.th, alarmStr).Layout)
+			}))
+	}
+
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(material.RadioButton(ss.th,
+					&ss.targetEnum, "stay", "Stay").Layout),
+				layout.Rigid(material.RadioButton(ss.th,
+					&ss.targetEnum, "away", "Away").Layout),
+				layout.Rigid(material.RadioButton(ss.th,
+					&ss.targetEnum, "night", "Night").Layout),
+				layout.Rigid(material.RadioButton(ss.th,
+					&ss.targetEnum, "disarm", "Disarm").Layout),
+			)
+		}))
+
+	pinField := func(gtx C) D {
+		return material.Editor(ss.th, &ss.pinInput, "PIN").Layout(gtx)
+	}
+
+	if ss.confirmDisarm {
+		children = append(children,
+			layout.Rigid(material.Body1(ss.th, fmt.Sprintf("Disarm %s?", ss.label)).Layout))
+		if ss.pinHash != "" {
+			children = append(children, layout.Rigid(pinField))
+		}
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(material.Button(ss.th, &ss.confirmDisarmBtn, "Disarm").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(ss.th, &ss.cancelDisarmBtn, "Cancel").Layout),
+				)
+			}))
+	}
+
+	if ss.pinError != "" {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(ss.th, ss.pinError)
+				lbl.Color = alertColor
+				return lbl.Layout(gtx)
+			}))
+	}
+
+	// PIN settings
+	pinStr := "not set"
+	if ss.pinHash != "" {
+		pinStr = "set"
+	}
+	children = append(children,
+		layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+		layout.Rigid(material.Body2(ss.th, fmt.Sprintf("Disarm PIN: %s", pinStr)).Layout))
+	if ss.pinHash != "" && !ss.confirmDisarm {
+		children = append(children, layout.Rigid(pinField))
+	}
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return material.Editor(ss.th, &ss.newPinInput, "New PIN").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			btns := []layout.FlexChild{
+				layout.Rigid(material.Button(ss.th, &ss.setPinBtn, "Set PIN").Layout),
+			}
+			if ss.pinHash != "" {
+				btns = append(btns,
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(ss.th, &ss.clearPinBtn, "Clear PIN").Layout))
+			}
+			return layout.Flex{}.Layout(gtx, btns...)
+		}))
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func securityCurrentState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "armed stay"
+		case 1:
+			valStr = "armed away"
+		case 2:
+			valStr = "armed night"
+		case 3:
+			valStr = "disarmed"
+		case 4:
+			valStr = "alarm triggered"
+		}
+	}
+	return valStr
+}
+func securityTargetState2enum(v interface{}) string {
+	valStr := ""
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "stay"
+		case 1:
+			valStr = "away"
+		case 2:
+			valStr = "night"
+		case 3:
+			valStr = "disarm"
+		}
+	}
+	return valStr
+}
+func securityTargetState2num(v string) int {
+	valNum := securityDisarm
+	switch v {
+	case "stay":
+		valNum = 0
+	case "away":
+		valNum = 1
+	case "night":
+		valNum = 2
+	}
+	return valNum
+}
@@ -0,0 +1,41 @@
+package service_cards
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPbkdf2(t *testing.T) {
+	// RFC 7914, section 11
+	got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1))
+	if want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"; got != want {
+		t.Errorf("pbkdf2 = %s, want %s", got, want)
+	}
+}
+
+func TestPinHash(t *testing.T) {
+	first, err := pinHashFor("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pinHashFor("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("same PIN hashed with same salt")
+	}
+	if !pinMatches("1234", first) || !pinMatches("1234", second) {
+		t.Error("PIN does not match its hash")
+	}
+	for _, pin := range []string{"", "4321", "12345"} {
+		if pinMatches(pin, first) {
+			t.Errorf("PIN %q matches hash of 1234", pin)
+		}
+	}
+	for _, stored := range []string{"", "1234", "zz$00", "00$zz"} {
+		if pinMatches("1234", stored) {
+			t.Errorf("PIN matches malformed hash %q", stored)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+// sensorReading describes single value shown by read-only sensor card
+type sensorReading struct {
+	ctype  hkontroller.HapCharacteristicType
+	name   string
+	format func(v interface{}) string
+}
+
+// sensorSpec describes read-only sensor service
+type sensorSpec struct {
+	// main reading shown in quick card as well
+	primary sensorReading
+	// optional readings shown only in full card
+	extra []sensorReading
+}
+
+var sensorSpecs = map[hkontroller.HapServiceType]sensorSpec{
+	hkontroller.SType_TemperatureSensor: {
+		primary: sensorReading{hkontroller.CType_CurrentTemperature, "Temperature", withUnit("°C")},
+	},
+	hkontroller.SType_HumiditySensor: {
+		primary: sensorReading{hkontroller.CType_CurrentRelativeHumidity, "Humidity", withUnit("%")},
+	},
+	hkontroller.SType_LightSensor: {
+		primary: sensorReading{hkontroller.CType_CurrentAmbientLightLevel, "Light", withUnit("lx")},
+	},
+	hkontroller.SType_CarbonDioxideSensor: {
+		primary: sensorReading{hkontroller.CType_CarbonDioxideDetected, "CO2", co2Detected2str},
+		extra: []sensorReading{
+			{hkontroller.CType_CarbonDioxideLevel, "CO2 level", withUnit("ppm")},
+			{hkontroller.CType_CarbonDioxidePeakLevel, "CO2 peak level", withUnit("ppm")},
+		},
+	},
+	hkontroller.SType_AirQualitySensor: {
+		primary: sensorReading{hkontroller.CType_AirQuality, "Air quality", airQuality2str},
+		extra: []sensorReading{
+			{hkontroller.CType_PM2_5Density, "PM2.5", withUnit("µg/m³")},
+			{hkontroller.CType_PM10Density, "PM10", withUnit("µg/m³")},
+			{hkontroller.CType_OzoneDensity, "Ozone", withUnit("µg/m³")},
+			{hkontroller.CType_NitrogenDioxideDensity, "NO2", withUnit("µg/m³")},
+			{hkontroller.CType_SulphurDioxideDensity, "SO2", withUnit("µg/m³")},
+			{hkontroller.CType_VOCDensity, "VOC", withUnit("µg/m³")},
+		},
+	},
+}
+
+// status characteristics common for sensors
+var sensorStatusChars = []hkontroller.HapCharacteristicType{
+	hkontroller.CType_StatusActive,
+	hkontroller.CType_StatusFault,
+	hkontroller.CType_StatusLowBattery,
+	hkontroller.CType_StatusTampered,
+}
+
+// Sensor is a read-only card for sensors listed in sensorSpecs
+type Sensor struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+	spec  sensorSpec
+
+	*serviceChars
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewSensor(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Sensor, error) {
+	spec, ok := sensorSpecs[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sensor %s", s.Type.String())
+	}
+
+	sn := &Sensor{
+		quick:        quickWidget,
+		spec:         spec,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	sn.label = label
+
+	err = sn.require(spec.primary.ctype)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range spec.extra {
+		sn.optional(r.ctype)
+	}
+	sn.optional(sensorStatusChars...)
+	sn.fetch()
+
+	return sn, nil
+}
+
+func (s *Sensor) Layout(gtx C) D {
+	primary := s.spec.primary
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body1(s.th, primary.name).Layout,
+				material.Body1(s.th, primary.format(s.value(primary.ctype))).Layout)
+		}))
+
+	if !s.quick {
+		for _, r := range s.spec.extra {
+			r := r
+			if !s.has(r.ctype) {
+				continue
+			}
+			children = append(children,
+				layout.Rigid(func(gtx C) D {
+					return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+						material.Body2(s.th, r.name).Layout,
+						material.Body2(s.th, r.format(s.value(r.ctype))).Layout)
+				}))
+		}
+	}
+
+	if badges := statusBadges(s.th, s.serviceChars); len(badges) > 0 {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx, badges...)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// statusBadges returns badges for StatusActive, StatusFault,
+// StatusLowBattery and StatusTampered in not normal state
+func statusBadges(th *material.Theme, c *serviceChars) []layout.FlexChild {
+	var badges []string
+	if c.has(hkontroller.CType_StatusActive) &&
+		!boolValue(c.value(hkontroller.CType_StatusActive)) {
+		badges = append(badges, "inactive")
+	}
+	if boolValue(c.value(hkontroller.CType_StatusFault)) {
+		badges = append(badges, "fault")
+	}
+	if boolValue(c.value(hkontroller.CType_StatusLowBattery)) {
+		badges = append(badges, "low battery")
+	}
+	if boolValue(c.value(hkontroller.CType_StatusTampered)) {
+		badges = append(badges, "tampered")
+	}
+
+	var children []layout.FlexChild
+	for _, b := range badges {
+		b := b
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+					return badge(th, b, color.NRGBA{R: 200, G: 100, A: 255})(gtx)
+				})
+			}))
+	}
+	return children
+}
+
+func badge(th *material.Theme, text string, c color.NRGBA) layout.Widget {
+	return func(gtx C) D {
+		return widget.Border{
+			Color:        c,
+			Width:        unit.Dp(1),
+			CornerRadius: unit.Dp(3),
+		}.Layout(gtx, func(gtx C) D {
+			return layout.UniformInset(unit.Dp(2)).Layout(gtx, func(gtx C) D {
+				lbl := material.Caption(th, text)
+				lbl.Color = c
+				return lbl.Layout(gtx)
+			})
+		})
+	}
+}
+
+// util
+// ------------------
+func co2Detected2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "normal"
+		case 1:
+			valStr = "abnormal"
+		}
+	}
+	return valStr
+}
+func airQuality2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 1:
+			valStr = "excellent"
+		case 2:
+			valStr = "good"
+		case 3:
+			valStr = "fair"
+		case 4:
+			valStr = "inferior"
+		case 5:
+			valStr = "poor"
+		}
+	}
+	return valStr
+}
@@ -0,0 +1,141 @@
+package service_cards
+
+import (
+	"hkapp/applayout"
+	"hkapp/application"
+
+	"gioui.org/layout"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+Current Slat State : 0-Fixed 1-Jammed 2-Swinging
+Slat Type : 0-Horizontal 1-Vertical
+
+Optional Characteristics
+Name
+Swing Mode : 0-Disabled 1-Enabled
+Current Tilt Angle : -90-90
+Target Tilt Angle : -90-90
+*/
+
+type Slat struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	pos *positionControl
+
+	swing widget.Bool
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewSlat(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Slat, error) {
+	sl := &Slat{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	sl.serviceChars.onValue = sl.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	sl.label = label
+
+	err = sl.require(
+		hkontroller.CType_CurrentSlatState,
+		hkontroller.CType_SlatType)
+	if err != nil {
+		return nil, err
+	}
+	sl.optional(
+		hkontroller.CType_SwingMode,
+		hkontroller.CType_CurrentTiltAngle,
+		hkontroller.CType_TargetTiltAngle)
+	sl.pos = newPositionControl(sl.serviceChars, sl.th)
+	sl.fetch()
+
+	return sl, nil
+}
+
+func (sl *Slat) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	if ctype == hkontroller.CType_SwingMode {
+		sl.swing.Value = boolValue(value)
+		return
+	}
+	sl.pos.onValue(value, ctype, source)
+}
+
+func (sl *Slat) Layout(gtx C) D {
+
+	sl.pos.update()
+	if sl.swing.Changed() {
+		sl.put(hkontroller.CType_SwingMode, bool2num(sl.swing.Value))
+	}
+
+	stateStr := slatState2str(sl.value(hkontroller.CType_CurrentSlatState))
+	typeStr := slatType2str(sl.value(hkontroller.CType_SlatType))
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			state := material.Body1(sl.th, stateStr)
+			if s, ok := intValue(sl.value(hkontroller.CType_CurrentSlatState)); ok && s == 1 {
+				state.Color = alertColor
+			}
+			return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+				material.Body1(sl.th, typeStr+" slats").Layout,
+				state.Layout)
+		}))
+	children = append(children, sl.pos.layout(sl.quick)...)
+
+	if !sl.quick && sl.has(hkontroller.CType_SwingMode) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+					material.Body1(sl.th, "Swing").Layout,
+					material.Switch(sl.th, &sl.swing, "Swing").Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func slatState2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "fixed"
+		case 1:
+			valStr = "jammed"
+		case 2:
+			valStr = "swinging"
+		}
+	}
+	return valStr
+}
+func slatType2str(v interface{}) string {
+	valStr := "Horizontal"
+	if m, ok := intValue(v); ok && m == 1 {
+		valStr = "Vertical"
+	}
+	return valStr
+}
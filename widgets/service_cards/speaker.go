@@ -0,0 +1,151 @@
+package service_cards
+
+import (
+	"hkapp/applayout"
+	"hkapp/application"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const volumeDragDelay = 300 * time.Millisecond
+
+/*
+Speaker, Television Speaker
+Characteristics without number are not in HAP R2 spec
+
+Required Characteristics
+9.61 Mute
+
+Optional Characteristics
+9.1 Active
+9.126 Volume : 0-100
+Volume Control Type : 0-None 1-Relative 2-Relative with current 3-Absolute
+Volume Selector : 0-Increment 1-Decrement, write only
+*/
+
+type Speaker struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	mute         widget.Bool
+	volumeWidget widget.Float
+	volumeUpBtn  widget.Clickable
+	volumeDnBtn  widget.Clickable
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewSpeaker(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Speaker, error) {
+	sp := &Speaker{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	sp.serviceChars.onValue = sp.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	sp.label = label
+
+	err = sp.require(hkontroller.CType_Mute)
+	if err != nil {
+		return nil, err
+	}
+	sp.optional(
+		hkontroller.CType_Active,
+		hkontroller.CType_Volume,
+		hkontroller.CType_VolumeSelector)
+	sp.fetch()
+
+	return sp, nil
+}
+
+func (sp *Speaker) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Mute:
+		sp.mute.Value = boolValue(value)
+	case hkontroller.CType_Volume:
+		if v, ok := floatValue(value); ok {
+			sp.volumeWidget.Value = float32(v)
+		}
+	}
+}
+
+func (sp *Speaker) QuickAction() {
+	sp.mute.Value = !sp.mute.Value
+	sp.put(hkontroller.CType_Mute, sp.mute.Value)
+}
+
+func (sp *Speaker) Layout(gtx C) D {
+
+	if sp.mute.Changed() {
+		sp.put(hkontroller.CType_Mute, sp.mute.Value)
+	}
+	min, max, step := sp.valueRange(hkontroller.CType_Volume, 0, 100, 1)
+	if sliderDragged(&sp.volumeWidget) {
+		sp.putDebounced(hkontroller.CType_Volume, volumeDragDelay, func() interface{} {
+			return roundToStep(float64(sp.volumeWidget.Value), min, step)
+		})
+	}
+	for sp.volumeUpBtn.Clicked() {
+		sp.put(hkontroller.CType_VolumeSelector, 0)
+	}
+	for sp.volumeDnBtn.Clicked() {
+		sp.put(hkontroller.CType_VolumeSelector, 1)
+	}
+
+	volumeStr := ""
+	if sp.has(hkontroller.CType_Volume) {
+		volumeStr = withUnit("%")(sp.value(hkontroller.CType_Volume))
+	}
+	if sp.mute.Value {
+		volumeStr = "muted"
+	}
+
+	if sp.quick {
+		return material.Body1(sp.th, "Volume: "+volumeStr).Layout(gtx)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(sp.th, "Mute").Layout,
+				material.Switch(sp.th, &sp.mute, "Mute").Layout)
+		}))
+	if sp.has(hkontroller.CType_Volume) {
+		children = append(children,
+			layout.Rigid(material.Body1(sp.th, "Volume: "+volumeStr).Layout),
+			layout.Rigid(material.Slider(sp.th, &sp.volumeWidget, float32(min), float32(max)).Layout))
+	} else if sp.has(hkontroller.CType_VolumeSelector) {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(material.Body1(sp.th, "Volume").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(sp.th, &sp.volumeDnBtn, "-").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+					layout.Rigid(material.Button(sp.th, &sp.volumeUpBtn, "+").Layout),
+				)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
@@ -11,7 +11,6 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 )
 
 type Switch struct {
@@ -22,11 +21,7 @@ type Switch struct {
 
 	label string
 
-	acc *hkontroller.Accessory
-	dev *hkontroller.Device
-
-	hapEvents <-chan emitter.Event
-	guiEvents <-chan emitter.Event
+	*serviceChars
 
 	th *material.Theme
 
@@ -37,156 +32,55 @@ func NewSwitch(app *application.App,
 	acc *hkontroller.Accessory,
 	dev *hkontroller.Device,
 	quickWidget bool) (*Switch, error) {
-	s := &Switch{
-		quick: quickWidget,
-		acc:   acc,
-		dev:   dev,
-		th:    app.Theme,
-		App:   app,
-	}
 
-	infoS := acc.GetService(hkontroller.SType_AccessoryInfo)
-	if infoS == nil {
-		return nil, errors.New("cannot get AccessoryInfo service")
-	}
-	labelC := infoS.GetCharacteristic(hkontroller.CType_Name)
-	if labelC == nil {
-		return nil, errors.New("cannot get characteristic Name")
-	}
-	label, ok := labelC.Value.(string)
-	if !ok {
-		return nil, errors.New("cannot extract accessory name")
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
 	}
-	s.label = label
 
 	switchS := acc.GetService(hkontroller.SType_Switch)
 	if switchS == nil {
 		return nil, errors.New("cannot find Switch service")
 	}
-	onC := switchS.GetCharacteristic(hkontroller.CType_On)
-	if onC == nil {
-		return nil, errors.New("cannot find characteristic On")
-	}
-
-	convertOnValue := func(value interface{}, sw *Switch) {
-		onValue, ok := value.(bool)
-		if !ok {
-			var onValInt float64
-			onValInt, ok = value.(float64)
-			if ok {
-				onValue = onValInt > 0
-			}
-		}
-		sw.on = widget.Bool{Value: onValue}
-		sw.quickOn = widget.Bool{Value: onValue}
-	}
-	withValOnC, err := dev.GetCharacteristic(acc.Id, onC.Iid)
-	if err == nil {
-		convertOnValue(withValOnC.Value, s)
-	} else {
-		convertOnValue(onC.Value, s)
-	}
-	if !ok {
-		convertOnValue(false, s)
-	}
 
-	return s, nil
-}
-
-func (s *Switch) SubscribeToEvents() {
-	convertOnValue := func(value interface{}, sw *Switch) {
-		onValue, ok := value.(bool)
-		if !ok {
-			var onValInt float64
-			onValInt, ok = value.(float64)
-			if ok {
-				onValue = onValInt > 0
-			}
-		}
-		sw.on.Value = onValue
-		sw.quickOn.Value = onValue
-	}
-	switchS := s.acc.GetService(hkontroller.SType_Switch)
-	if switchS == nil {
-		return
-	}
-	onC := switchS.GetCharacteristic(hkontroller.CType_On)
-	if onC == nil {
-		return
+	s := &Switch{
+		quick:        quickWidget,
+		label:        label,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, switchS),
 	}
+	s.serviceChars.onValue = s.onValue
 
-	onEvent := func(e emitter.Event) {
-		value := e.Args[2]
-		convertOnValue(value, s)
-		s.App.Window.Invalidate()
+	if err := s.require(hkontroller.CType_On); err != nil {
+		return nil, err
 	}
+	s.fetch()
 
-	// hapEvents from HAP
-	events, err := s.dev.SubscribeToEvents(s.acc.Id, onC.Iid)
-	if err != nil {
-		return
-	}
-	s.hapEvents = events
-	go func(evs <-chan emitter.Event) {
-		for e := range evs {
-			onEvent(e)
-		}
-	}(events)
-
-	// hapEvents from GUI
-	vals := s.App.OnValueChange(s.dev.Name, s.acc.Id, onC.Iid)
-	s.guiEvents = vals
-	go func(evs <-chan emitter.Event) {
-		for e := range evs {
-			onEvent(e)
-		}
-	}(vals)
+	return s, nil
 }
 
-func (s *Switch) UnsubscribeFromEvents() {
-	switchS := s.acc.GetService(hkontroller.SType_Switch)
-	if switchS == nil {
-		return
-	}
-	onC := switchS.GetCharacteristic(hkontroller.CType_On)
-	if onC == nil {
-		return
+func (s *Switch) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	if ctype == hkontroller.CType_On {
+		s.on.Value = boolValue(value)
+		s.quickOn.Value = s.on.Value
 	}
-
-	s.dev.UnsubscribeFromEvents(s.acc.Id, onC.Iid, s.hapEvents)
-	s.App.OffValueChange(s.dev.Name, s.acc.Id, onC.Iid, s.guiEvents)
 }
 
 func (s *Switch) QuickAction() {
 	s.on.Value = !s.on.Value
+	s.quickOn.Value = s.on.Value
 	s.onBoolValueChanged()
 }
 
 func (s *Switch) onBoolValueChanged() error {
-
-	srv := s.acc.GetService(hkontroller.SType_Switch)
-	if srv == nil {
-		return errors.New("cannot find SwitchService characteristic")
-	}
-	chr := srv.GetCharacteristic(hkontroller.CType_On)
-	if chr == nil {
-		return errors.New("cannot find On characteristic")
-	}
-
-	go func() {
-		err := s.dev.PutCharacteristic(s.acc.Id, chr.Iid, s.on.Value)
-		if err != nil {
-			return
-		}
-
-		s.App.EmitValueChange(s.dev.Name, s.acc.Id, chr.Iid, s.on.Value)
-	}()
-
-	return nil
+	return s.put(hkontroller.CType_On, s.on.Value)
 }
 
 func (s *Switch) Layout(gtx C) D {
 
+	mirrorQuickSwitch(&s.quickOn, &s.on)
+
 	var err error
 	if s.on.Changed() {
 		err = s.onBoolValueChanged()
@@ -0,0 +1,317 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Television
+Characteristics without number are not in HAP R2 spec
+
+Required Characteristics
+9.1 Active
+Active Identifier : Identifier of selected InputSource
+Configured Name
+Sleep Discovery Mode
+Remote Key : write only
+
+Optional Characteristics
+9.11 Brightness
+Closed Captions
+Display Order
+Current Media State
+Target Media State
+Picture Mode
+Power Mode Selection
+
+Input Source (linked)
+Required Characteristics
+Configured Name
+Input Source Type
+9.47 Is Configured
+Current Visibility State : 0-Shown 1-Hidden
+
+Optional Characteristics
+Identifier
+Input Device Type
+Target Visibility State
+9.62 Name
+*/
+
+// HAP RemoteKey values
+const (
+	remoteKeyRewind      = 0
+	remoteKeyFastForward = 1
+	remoteKeyNextTrack   = 2
+	remoteKeyPrevTrack   = 3
+	remoteKeyArrowUp     = 4
+	remoteKeyArrowDown   = 5
+	remoteKeyArrowLeft   = 6
+	remoteKeyArrowRight  = 7
+	remoteKeySelect      = 8
+	remoteKeyBack        = 9
+	remoteKeyExit        = 10
+	remoteKeyPlayPause   = 11
+	remoteKeyInformation = 15
+)
+
+// tvInput is linked InputSource service
+type tvInput struct {
+	srv        *hkontroller.ServiceDescription
+	identifier string
+	name       string
+	// hidden inputs and inputs without identifier are not offered in picker
+	hidden bool
+}
+
+type Television struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	active      widget.Bool
+	quickActive widget.Bool
+
+	inputs    []tvInput
+	inputEnum widget.Enum
+
+	remoteKeys map[int]*widget.Clickable
+
+	speaker *Speaker
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewTelevision(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Television, error) {
+	t := &Television{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+		remoteKeys:   make(map[int]*widget.Clickable),
+	}
+	t.serviceChars.onValue = t.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	t.label = label
+
+	err = t.require(
+		hkontroller.CType_Active,
+		hkontroller.CType_ActiveIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	t.optional(
+		hkontroller.CType_ConfiguredName,
+		hkontroller.CType_RemoteKey)
+
+	for _, is := range linkedServices(acc, s, hkontroller.SType_InputSource) {
+		in := tvInput{srv: is, hidden: true}
+		if c := is.GetCharacteristic(hkontroller.CType_Identifier); c != nil {
+			if id, ok := intValue(c.Value); ok {
+				in.identifier = strconv.Itoa(id)
+				in.hidden = false
+			}
+		}
+		if c := is.GetCharacteristic(hkontroller.CType_CurrentVisibilityState); c != nil {
+			if hidden, _ := intValue(c.Value); hidden == 1 {
+				in.hidden = true
+			}
+		}
+		if c := is.GetCharacteristic(hkontroller.CType_ConfiguredName); c != nil {
+			in.name, _ = c.Value.(string)
+		}
+		if c := is.GetCharacteristic(hkontroller.CType_Name); c != nil && in.name == "" {
+			in.name, _ = c.Value.(string)
+		}
+		if in.name == "" {
+			in.name = "Input " + in.identifier
+		}
+		t.inputs = append(t.inputs, in)
+	}
+
+	if !quickWidget {
+		speakers := linkedServices(acc, s, hkontroller.SType_TelevisionSpeaker)
+		if len(speakers) == 0 {
+			speakers = linkedServices(acc, s, hkontroller.SType_Speaker)
+		}
+		if len(speakers) > 0 {
+			sp, err := NewSpeaker(app, acc, dev, speakers[0], false)
+			if err == nil {
+				t.speaker = sp
+			}
+		}
+	}
+
+	for _, k := range []int{
+		remoteKeyArrowUp, remoteKeyArrowDown, remoteKeyArrowLeft, remoteKeyArrowRight,
+		remoteKeySelect, remoteKeyBack, remoteKeyPlayPause, remoteKeyInformation,
+		remoteKeyRewind, remoteKeyFastForward, remoteKeyExit,
+	} {
+		t.remoteKeys[k] = new(widget.Clickable)
+	}
+
+	t.fetch()
+
+	return t, nil
+}
+
+func (t *Television) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		t.active.Value = boolValue(value)
+		t.quickActive.Value = t.active.Value
+	case hkontroller.CType_ActiveIdentifier:
+		if id, ok := intValue(value); ok {
+			t.inputEnum.Value = strconv.Itoa(id)
+		}
+	case hkontroller.CType_ConfiguredName:
+		if name, ok := value.(string); ok && name != "" {
+			t.label = name
+		}
+	}
+}
+
+// LinkedServices returns linked services rendered by this card
+func (t *Television) LinkedServices() []*hkontroller.ServiceDescription {
+	res := make([]*hkontroller.ServiceDescription, 0, len(t.inputs)+1)
+	for _, in := range t.inputs {
+		res = append(res, in.srv)
+	}
+	if t.speaker != nil {
+		res = append(res, t.speaker.srv)
+	}
+	return res
+}
+
+func (t *Television) SubscribeToEvents() {
+	t.serviceChars.SubscribeToEvents()
+	if t.speaker != nil {
+		t.speaker.SubscribeToEvents()
+	}
+}
+
+func (t *Television) UnsubscribeFromEvents() {
+	t.serviceChars.UnsubscribeFromEvents()
+	if t.speaker != nil {
+		t.speaker.UnsubscribeFromEvents()
+	}
+}
+
+func (t *Television) QuickAction() {
+	t.active.Value = !t.active.Value
+	t.quickActive.Value = t.active.Value
+	t.put(hkontroller.CType_Active, bool2num(t.active.Value))
+}
+
+func (t *Television) inputName(identifier string) string {
+	for _, in := range t.inputs {
+		if in.identifier != "" && in.identifier == identifier {
+			return in.name
+		}
+	}
+	return identifier
+}
+
+func (t *Television) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&t.quickActive, &t.active)
+	if t.active.Changed() {
+		t.put(hkontroller.CType_Active, bool2num(t.active.Value))
+	}
+	for t.inputEnum.Changed() {
+		if id, err := strconv.Atoi(t.inputEnum.Value); err == nil {
+			t.put(hkontroller.CType_ActiveIdentifier, id)
+		}
+	}
+	for k, btn := range t.remoteKeys {
+		for btn.Clicked() {
+			t.put(hkontroller.CType_RemoteKey, k)
+		}
+	}
+
+	inputStr := t.inputName(t.inputEnum.Value)
+
+	if t.quick {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(material.Switch(t.th, &t.quickActive, t.label).Layout),
+			layout.Rigid(material.Body1(t.th, " "+inputStr).Layout),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(t.th, t.label).Layout,
+				material.Switch(t.th, &t.active, t.label).Layout)
+		}),
+		layout.Rigid(material.Body2(t.th, fmt.Sprintf("Input: %s", inputStr)).Layout),
+	)
+	for _, in := range t.inputs {
+		if in.hidden {
+			continue
+		}
+		in := in
+		children = append(children,
+			layout.Rigid(material.RadioButton(t.th, &t.inputEnum, in.identifier, in.name).Layout))
+	}
+	if t.has(hkontroller.CType_RemoteKey) {
+		children = append(children,
+			layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+			layout.Rigid(t.layoutRemote))
+	}
+	if t.speaker != nil {
+		children = append(children,
+			layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+			layout.Rigid(t.speaker.Layout))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// layoutRemote lays out d-pad with media keys
+func (t *Television) layoutRemote(gtx C) D {
+	key := func(k int, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(2)).Layout(gtx,
+				material.Button(t.th, t.remoteKeys[k], label).Layout)
+		})
+	}
+	empty := layout.Rigid(func(gtx C) D {
+		return layout.Spacer{Width: unit.Dp(48)}.Layout(gtx)
+	})
+	row := func(children ...layout.FlexChild) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		})
+	}
+
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+		row(key(remoteKeyBack, "back"), key(remoteKeyArrowUp, "▲"), key(remoteKeyInformation, "info")),
+		row(key(remoteKeyArrowLeft, "◀"), key(remoteKeySelect, "OK"), key(remoteKeyArrowRight, "▶")),
+		row(empty, key(remoteKeyArrowDown, "▼"), empty),
+		row(key(remoteKeyRewind, "<<"), key(remoteKeyPlayPause, "play/pause"), key(remoteKeyFastForward, ">>")),
+		row(key(remoteKeyExit, "exit")),
+	)
+}
@@ -3,16 +3,15 @@ package service_cards
 import (
 	"errors"
 	"fmt"
+	"hkapp/applayout"
 	"hkapp/application"
 	"math"
 	"time"
 
-	"gioui.org/widget"
-
 	"gioui.org/layout"
+	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/hkontrol/hkontroller"
-	"github.com/olebedev/emitter"
 )
 
 const targetTempDragDelay = 300 * time.Millisecond
@@ -38,32 +37,15 @@ type Thermostat struct {
 
 	label string
 
-	acc *hkontroller.Accessory
-	dev *hkontroller.Device
-
-	hapEvents map[hkontroller.HapCharacteristicType]<-chan emitter.Event
-	guiEvents map[hkontroller.HapCharacteristicType]<-chan emitter.Event
-
-	/*
-		currentHeatCoolStateC *hkontroller.CharacteristicDescription
-		targetHeatCoolStateC  *hkontroller.CharacteristicDescription
-		currentTempC          *hkontroller.CharacteristicDescription
-		targetTempC           *hkontroller.CharacteristicDescription
-		tempDisplayUnitsC     *hkontroller.CharacteristicDescription
-
-		coolingThresholdTempC   *hkontroller.CharacteristicDescription
-		heatingThresholdTempC   *hkontroller.CharacteristicDescription
-		currentRelativeHumidity *hkontroller.CharacteristicDescription
-		targetRelativeHumidity  *hkontroller.CharacteristicDescription
-	*/
-
-	chars map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription
+	*serviceChars
 
 	targetModeEnum        widget.Enum
 	targetTempFloatWidget widget.Float
-	targetTempFloatValue  float32
 
-	dragTimer *time.Timer
+	// sliders of optional chars, temperatures are in display units
+	coolingWidget        widget.Float
+	heatingWidget        widget.Float
+	targetHumidityWidget widget.Float
 
 	th *material.Theme
 
@@ -74,232 +56,207 @@ func NewThermostat(app *application.App,
 	acc *hkontroller.Accessory,
 	dev *hkontroller.Device,
 	quickWidget bool) (*Thermostat, error) {
-	t := &Thermostat{
-		quick:     quickWidget,
-		App:       app,
-		acc:       acc,
-		dev:       dev,
-		th:        app.Theme,
-		chars:     make(map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription),
-		hapEvents: make(map[hkontroller.HapCharacteristicType]<-chan emitter.Event),
-		guiEvents: make(map[hkontroller.HapCharacteristicType]<-chan emitter.Event),
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
 	}
 
-	infoS := acc.GetService(hkontroller.SType_AccessoryInfo)
-	if infoS == nil {
-		return nil, errors.New("cannot get AccessoryInfo service")
-	}
-	labelC := infoS.GetCharacteristic(hkontroller.CType_Name)
-	if labelC == nil {
-		return nil, errors.New("cannot get characteristic Name")
-	}
-	label, ok := labelC.Value.(string)
-	if !ok {
-		return nil, errors.New("cannot extract accessory name")
-	}
-	t.label = label
-
 	srv := acc.GetService(hkontroller.SType_Thermostat)
 	if srv == nil {
 		return nil, errors.New("cannot find thermostat service")
 	}
 
-	// required chars
-	currentHeatCoolStateC := srv.GetCharacteristic(hkontroller.CType_CurrentHeatingCoolingState)
-	if currentHeatCoolStateC == nil {
-		return nil, errors.New("cannot find required characteristic")
-	}
-	targetHeatCoolStateC := srv.GetCharacteristic(hkontroller.CType_TargetHeatingCoolingState)
-	if targetHeatCoolStateC == nil {
-		return nil, errors.New("cannot find required characteristic")
-	}
-	currentTempC := srv.GetCharacteristic(hkontroller.CType_CurrentTemperature)
-	if currentTempC == nil {
-		return nil, errors.New("cannot find required characteristic")
-	}
-	targetTempC := srv.GetCharacteristic(hkontroller.CType_TargetTemperature)
-	if targetTempC == nil {
-		return nil, errors.New("cannot find required characteristic")
-	}
-	tempDisplayUnitsC := srv.GetCharacteristic(hkontroller.CType_TemperatureDisplayUnits)
-	if tempDisplayUnitsC == nil {
-		return nil, errors.New("cannot find required characteristic")
-	}
-
-	t.chars[hkontroller.CType_CurrentHeatingCoolingState] = currentHeatCoolStateC
-	t.chars[hkontroller.CType_TargetHeatingCoolingState] = targetHeatCoolStateC
-	t.chars[hkontroller.CType_CurrentTemperature] = currentTempC
-	t.chars[hkontroller.CType_TargetTemperature] = targetTempC
-	t.chars[hkontroller.CType_TemperatureDisplayUnits] = tempDisplayUnitsC
-
-	// optional chars
-	coolingThresholdTempC := srv.GetCharacteristic(hkontroller.CType_CoolingThresholdTemperature)
-	heatingThresholdTempC := srv.GetCharacteristic(hkontroller.CType_HeatingThresholdTemperature)
-	currentRelativeHumidity := srv.GetCharacteristic(hkontroller.CType_CurrentRelativeHumidity)
-	targetRelativeHumidity := srv.GetCharacteristic(hkontroller.CType_TargetRelativeHumidity)
-	if coolingThresholdTempC != nil {
-		t.chars[hkontroller.CType_CoolingThresholdTemperature] = coolingThresholdTempC
-	}
-	if heatingThresholdTempC != nil {
-		t.chars[hkontroller.CType_HeatingThresholdTemperature] = heatingThresholdTempC
-	}
-	if currentRelativeHumidity != nil {
-		t.chars[hkontroller.CType_CurrentRelativeHumidity] = currentRelativeHumidity
+	t := &Thermostat{
+		quick:        quickWidget,
+		label:        label,
+		App:          app,
+		th:           app.Theme,
+		serviceChars: newServiceChars(app, acc, dev, srv),
 	}
-	if targetRelativeHumidity != nil {
-		t.chars[hkontroller.CType_TargetRelativeHumidity] = targetRelativeHumidity
+	t.serviceChars.onValue = t.onValue
+
+	err = t.require(hkontroller.CType_CurrentHeatingCoolingState,
+		hkontroller.CType_TargetHeatingCoolingState,
+		hkontroller.CType_CurrentTemperature,
+		hkontroller.CType_TargetTemperature,
+		hkontroller.CType_TemperatureDisplayUnits)
+	if err != nil {
+		return nil, err
 	}
-
-	for ctype, cdescr := range t.chars {
-		// TODO: for such a case one should collect multiple chars and Get them
-		//        should be implemented in core hkontroller package as well
-		//         think also about saving in hkontroller.Device instance
-		descr, err := t.dev.GetCharacteristic(t.acc.Id, cdescr.Iid)
-		if err != nil {
-			fmt.Println("err: ", err)
-			continue
-		}
-		t.chars[ctype] = &descr
-		t.onValue(descr.Value, ctype)
-	}
-	t.App.Window.Invalidate()
+	t.optional(hkontroller.CType_CoolingThresholdTemperature,
+		hkontroller.CType_HeatingThresholdTemperature,
+		hkontroller.CType_CurrentRelativeHumidity,
+		hkontroller.CType_TargetRelativeHumidity)
+	t.fetch()
 
 	return t, nil
 }
 
 func (t *Thermostat) onValue(value interface{},
-	ctype hkontroller.HapCharacteristicType) {
+	ctype hkontroller.HapCharacteristicType, source application.Source) {
 
-	t.chars[ctype].Value = value
 	if ctype == hkontroller.CType_TargetTemperature {
-		if val32, ok := value.(float32); ok {
-			t.targetTempFloatWidget.Value = val32
-			t.targetTempFloatValue = val32
+		if val, ok := floatValue(value); ok {
+			t.targetTempFloatWidget.Value = t.toDisplay(val)
 		}
-		if val64, ok := value.(float64); ok {
-			t.targetTempFloatWidget.Value = float32(val64)
-			t.targetTempFloatValue = float32(val64)
+	}
+	if ctype == hkontroller.CType_CoolingThresholdTemperature {
+		if val, ok := floatValue(value); ok {
+			t.coolingWidget.Value = t.toDisplay(val)
 		}
 	}
-	if ctype == hkontroller.CType_TargetHeatingCoolingState {
-		var valNum float64
-		if vf, ok := value.(float64); ok {
-			valNum = vf
-		} else if vi, ok := value.(int); ok {
-			valNum = float64(vi)
-		} else {
-			return
+	if ctype == hkontroller.CType_HeatingThresholdTemperature {
+		if val, ok := floatValue(value); ok {
+			t.heatingWidget.Value = t.toDisplay(val)
 		}
+	}
+	if ctype == hkontroller.CType_TargetRelativeHumidity {
+		if val, ok := floatValue(value); ok {
+			t.targetHumidityWidget.Value = float32(val)
+		}
+	}
+	if ctype == hkontroller.CType_TemperatureDisplayUnits {
+		// sliders hold values in display units, so convert them again
+		for _, tc := range []hkontroller.HapCharacteristicType{
+			hkontroller.CType_TargetTemperature,
+			hkontroller.CType_CoolingThresholdTemperature,
+			hkontroller.CType_HeatingThresholdTemperature,
+		} {
+			if cc, ok := t.chars[tc]; ok && cc.Value != nil {
+				t.onValue(cc.Value, tc, source)
+			}
+		}
+	}
+	if ctype == hkontroller.CType_TargetHeatingCoolingState {
+		t.targetModeEnum.Value = targetMode2enum(value)
+	}
+}
 
-		t.targetModeEnum.Value = targetMode2enum(valNum)
+// fahrenheit reports whether device asks to display temperature in °F
+func (t *Thermostat) fahrenheit() bool {
+	v, ok := intValue(t.chars[hkontroller.CType_TemperatureDisplayUnits].Value)
+	return ok && v == 1
+}
+
+// toDisplay converts Celsius value to display units
+func (t *Thermostat) toDisplay(c float64) float32 {
+	if t.fahrenheit() {
+		return float32(c*9/5 + 32)
 	}
+	return float32(c)
 }
 
-func (t *Thermostat) SubscribeToEvents() {
+// fromDisplay converts slider value back to Celsius with one digit after point
+func (t *Thermostat) fromDisplay(v float32) float32 {
+	c := float64(v)
+	if t.fahrenheit() {
+		c = (c - 32) * 5 / 9
+	}
+	return float32(math.Round(c*10) / 10)
+}
 
-	var err error
-	var ev <-chan emitter.Event
-	devId := t.dev.Name
-	aid := t.acc.Id
-	onEvent := func(e emitter.Event, ctype hkontroller.HapCharacteristicType) {
-		value := e.Args[2]
-		t.onValue(value, ctype)
-		t.App.Window.Invalidate()
+// tempStr formats Celsius value in display units
+func (t *Thermostat) tempStr(v interface{}) string {
+	c, ok := floatValue(v)
+	if !ok {
+		return "--"
 	}
-	for ctype, cdescr := range t.chars {
-		iid := cdescr.Iid
-		ev, err = t.dev.SubscribeToEvents(aid, iid)
-		if err != nil {
-			fmt.Println("err subscribing: ", cdescr.Type.String(), err)
-			continue
+	if t.fahrenheit() {
+		return fmt.Sprintf("%.1f°F", t.toDisplay(c))
+	}
+	return fmt.Sprintf("%.1f°C", c)
+}
+
+// tempRange returns slider range in display units
+func (t *Thermostat) tempRange(ctype hkontroller.HapCharacteristicType, min, max float64) (float32, float32) {
+	if cc, ok := t.chars[ctype]; ok {
+		if v, ok := floatValue(cc.MinValue); ok {
+			min = v
+		}
+		if v, ok := floatValue(cc.MaxValue); ok {
+			max = v
 		}
-		go func(evs <-chan emitter.Event, ct hkontroller.HapCharacteristicType) {
-			for e := range evs {
-				onEvent(e, ct)
-			}
-		}(ev, ctype)
-		t.hapEvents[ctype] = ev
-
-		ev = t.App.OnValueChange(devId, aid, iid)
-		t.guiEvents[ctype] = ev
-		go func(evs <-chan emitter.Event, ct hkontroller.HapCharacteristicType) {
-			for e := range evs {
-				onEvent(e, ct)
-			}
-		}(ev, ctype)
 	}
+	return t.toDisplay(min), t.toDisplay(max)
+}
 
-	return
+// clampThresholds keeps heating threshold not above cooling one.
+// Threshold being dragged wins, the other one is moved to it.
+func clampThresholds(heat, cool float32, heatDragged bool) (float32, float32) {
+	if heat <= cool {
+		return heat, cool
+	}
+	if heatDragged {
+		return heat, heat
+	}
+	return cool, cool
 }
 
-func (t *Thermostat) UnsubscribeFromEvents() {
-	aid := t.acc.Id
-	devId := t.dev.Name
-	for ctype, ee := range t.hapEvents {
-		iid := t.chars[ctype].Iid
-		err := t.dev.UnsubscribeFromEvents(aid, iid, ee)
-		if err != nil {
-			continue
-		}
-		delete(t.hapEvents, ctype)
+// thresholds returns values of threshold sliders to write them in one batch
+func (t *Thermostat) thresholds() map[hkontroller.HapCharacteristicType]interface{} {
+	values := make(map[hkontroller.HapCharacteristicType]interface{})
+	if t.has(hkontroller.CType_HeatingThresholdTemperature) {
+		values[hkontroller.CType_HeatingThresholdTemperature] = t.fromDisplay(t.heatingWidget.Value)
 	}
-	for ctype, ee := range t.guiEvents {
-		iid := t.chars[ctype].Iid
-		t.App.OffValueChange(devId, aid, iid, ee)
-		delete(t.hapEvents, ctype)
+	if t.has(hkontroller.CType_CoolingThresholdTemperature) {
+		values[hkontroller.CType_CoolingThresholdTemperature] = t.fromDisplay(t.coolingWidget.Value)
 	}
-	return
+	return values
 }
 
 func (t *Thermostat) Layout(gtx C) D {
 
-	for t.targetTempFloatWidget.Changed() {
-		if t.targetTempFloatValue == 0 { // should trigger when widget is created
-			t.targetTempFloatValue = t.targetTempFloatWidget.Value
-			continue
+	if sliderDragged(&t.targetTempFloatWidget) {
+		// timer to prevent change on drag
+		t.putDebounced(hkontroller.CType_TargetTemperature, targetTempDragDelay, func() interface{} {
+			// in Celsius, one digit after point
+			return t.fromDisplay(t.targetTempFloatWidget.Value)
+		})
+	}
+	if sliderDragged(&t.heatingWidget) {
+		if t.has(hkontroller.CType_CoolingThresholdTemperature) {
+			t.heatingWidget.Value, t.coolingWidget.Value =
+				clampThresholds(t.heatingWidget.Value, t.coolingWidget.Value, true)
 		}
-
-		if t.dragTimer != nil {
-			t.dragTimer.Stop()
+		t.putManyDebounced(hkontroller.CType_HeatingThresholdTemperature, targetTempDragDelay, t.thresholds)
+	}
+	if sliderDragged(&t.coolingWidget) {
+		if t.has(hkontroller.CType_HeatingThresholdTemperature) {
+			t.heatingWidget.Value, t.coolingWidget.Value =
+				clampThresholds(t.heatingWidget.Value, t.coolingWidget.Value, false)
 		}
-
-		// timer to prevent change on drag
-		t.dragTimer = time.AfterFunc(targetTempDragDelay, func() {
-			val := float64(t.targetTempFloatWidget.Value)
-			// one digit after point
-			val = math.Floor(val*10) / 10
-			ctype := hkontroller.CType_TargetTemperature
-			go func() {
-				err := t.dev.PutCharacteristic(t.acc.Id, t.chars[ctype].Iid, float32(val))
-				if err != nil {
-					return
-				}
-				t.App.EmitValueChange(t.dev.Name, t.acc.Id, t.chars[ctype].Iid, float32(val))
-			}()
+		t.putManyDebounced(hkontroller.CType_CoolingThresholdTemperature, targetTempDragDelay, t.thresholds)
+	}
+	if sliderDragged(&t.targetHumidityWidget) {
+		t.putDebounced(hkontroller.CType_TargetRelativeHumidity, targetTempDragDelay, func() interface{} {
+			return math.Round(float64(t.targetHumidityWidget.Value))
 		})
 	}
 	for t.targetModeEnum.Changed() {
 		valStr := t.targetModeEnum.Value
 		valNum := targetMode2num(valStr)
-		ctype := hkontroller.CType_TargetHeatingCoolingState
-		go func() {
-			err := t.dev.PutCharacteristic(t.acc.Id, t.chars[ctype].Iid, valNum)
-			if err != nil {
-				return
-			}
-			t.App.EmitValueChange(t.dev.Name, t.acc.Id, t.chars[ctype].Iid, valNum)
-		}()
+		t.put(hkontroller.CType_TargetHeatingCoolingState, valNum)
 	}
 
-	ctemp := t.chars[hkontroller.CType_CurrentTemperature].Value
-	ttemp := t.chars[hkontroller.CType_TargetTemperature].Value
+	ctemp := t.tempStr(t.chars[hkontroller.CType_CurrentTemperature].Value)
+	ttemp := t.tempStr(t.chars[hkontroller.CType_TargetTemperature].Value)
 	cmode := t.chars[hkontroller.CType_CurrentHeatingCoolingState].Value
 	tmode := t.chars[hkontroller.CType_TargetHeatingCoolingState].Value
 
 	cmodeStr := currentMode2str(cmode)
 	tmodeStr := targetMode2enum(tmode)
 
-	// TODO optional chars
+	_, hasCooling := t.chars[hkontroller.CType_CoolingThresholdTemperature]
+	_, hasHeating := t.chars[hkontroller.CType_HeatingThresholdTemperature]
+	// in auto mode device keeps temperature between thresholds
+	autoRange := tmodeStr == "auto" && hasCooling && hasHeating
+
+	targetStr := fmt.Sprintf("Target: %v", ttemp)
+	if autoRange {
+		targetStr = fmt.Sprintf("Range: %v - %v",
+			t.tempStr(t.chars[hkontroller.CType_HeatingThresholdTemperature].Value),
+			t.tempStr(t.chars[hkontroller.CType_CoolingThresholdTemperature].Value))
+	}
 
 	if t.quick {
 		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
@@ -307,8 +264,7 @@ func (t *Thermostat) Layout(gtx C) D {
 				return layout.Flex{}.Layout(gtx,
 					layout.Rigid(material.Body1(t.th,
 						fmt.Sprintf("Temp: %v | ", ctemp)).Layout),
-					layout.Rigid(material.Body1(t.th,
-						fmt.Sprintf("Target: %v", ttemp)).Layout),
+					layout.Rigid(material.Body1(t.th, targetStr).Layout),
 				)
 			}),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -321,16 +277,30 @@ func (t *Thermostat) Layout(gtx C) D {
 			}),
 		)
 	} else {
-		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		var children []layout.FlexChild
+		children = append(children,
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Flex{}.Layout(gtx,
 					layout.Rigid(material.Body1(t.th,
 						fmt.Sprintf("Temp: %v | ", ctemp)).Layout),
-					layout.Rigid(material.Body1(t.th,
-						fmt.Sprintf("Target: %v", ttemp)).Layout),
+					layout.Rigid(material.Body1(t.th, targetStr).Layout),
 				)
-			}),
-			layout.Rigid(material.Slider(t.th, &t.targetTempFloatWidget, 10, 38).Layout),
+			}))
+		if autoRange {
+			heatMin, heatMax := t.tempRange(hkontroller.CType_HeatingThresholdTemperature, 0, 25)
+			coolMin, coolMax := t.tempRange(hkontroller.CType_CoolingThresholdTemperature, 10, 35)
+			children = append(children,
+				layout.Rigid(material.Body2(t.th, "Heat below").Layout),
+				layout.Rigid(material.Slider(t.th, &t.heatingWidget, heatMin, heatMax).Layout),
+				layout.Rigid(material.Body2(t.th, "Cool above").Layout),
+				layout.Rigid(material.Slider(t.th, &t.coolingWidget, coolMin, coolMax).Layout),
+			)
+		} else {
+			min, max := t.tempRange(hkontroller.CType_TargetTemperature, 10, 38)
+			children = append(children,
+				layout.Rigid(material.Slider(t.th, &t.targetTempFloatWidget, min, max).Layout))
+		}
+		children = append(children,
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return layout.Flex{}.Layout(gtx,
 					layout.Rigid(material.RadioButton(t.th,
@@ -352,6 +322,29 @@ func (t *Thermostat) Layout(gtx C) D {
 				)
 			}),
 		)
+		if c, ok := t.chars[hkontroller.CType_CurrentRelativeHumidity]; ok {
+			humStr := withUnit("%")(c.Value)
+			children = append(children,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return applayout.DetailRow{PrimaryWidth: 0.5}.Layout(gtx,
+						material.Body2(t.th, "Humidity").Layout,
+						material.Body2(t.th, humStr).Layout)
+				}))
+		}
+		if c, ok := t.chars[hkontroller.CType_TargetRelativeHumidity]; ok {
+			min, max := float32(0), float32(100)
+			if v, ok := floatValue(c.MinValue); ok {
+				min = float32(v)
+			}
+			if v, ok := floatValue(c.MaxValue); ok {
+				max = float32(v)
+			}
+			children = append(children,
+				layout.Rigid(material.Body2(t.th,
+					fmt.Sprintf("Target humidity: %s", withUnit("%")(c.Value))).Layout),
+				layout.Rigid(material.Slider(t.th, &t.targetHumidityWidget, min, max).Layout))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
 	}
 }
 
@@ -359,7 +352,7 @@ func (t *Thermostat) Layout(gtx C) D {
 // ------------------
 func currentMode2str(v interface{}) string {
 	valStr := "unknown"
-	if m, ok := v.(float64); ok {
+	if m, ok := floatValue(v); ok {
 		switch m {
 		case 0:
 			valStr = "off"
@@ -373,7 +366,7 @@ func currentMode2str(v interface{}) string {
 }
 func targetMode2enum(v interface{}) string {
 	valStr := ""
-	if m, ok := v.(float64); ok {
+	if m, ok := floatValue(v); ok {
 		switch m {
 		case 0:
 			valStr = "off"
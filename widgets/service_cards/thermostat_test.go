@@ -0,0 +1,90 @@
+package service_cards
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hkontrol/hkontroller"
+)
+
+func newTestThermostat(units interface{}) *Thermostat {
+	return &Thermostat{
+		serviceChars: &serviceChars{
+			chars: map[hkontroller.HapCharacteristicType]*hkontroller.CharacteristicDescription{
+				hkontroller.CType_TemperatureDisplayUnits: {Value: units},
+			},
+		},
+	}
+}
+
+func TestThermostatDisplayUnits(t *testing.T) {
+	tests := []struct {
+		name    string
+		units   interface{}
+		celsius float64
+		display float32
+		str     string
+	}{
+		{"celsius", 0.0, 21.5, 21.5, "21.5°C"},
+		{"fahrenheit", 1.0, 21.5, 70.7, "70.7°F"},
+		{"fahrenheit freezing", 1.0, 0, 32, "32.0°F"},
+		{"fahrenheit below zero", 1.0, -40, -40, "-40.0°F"},
+		{"fahrenheit as int", 1, 100, 212, "212.0°F"},
+		{"unknown units", nil, 10, 10, "10.0°C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestThermostat(tt.units)
+			if got := th.toDisplay(tt.celsius); math.Abs(float64(got-tt.display)) > 0.01 {
+				t.Errorf("toDisplay(%v) = %v, want %v", tt.celsius, got, tt.display)
+			}
+			if got := th.fromDisplay(tt.display); math.Abs(float64(got)-tt.celsius) > 0.01 {
+				t.Errorf("fromDisplay(%v) = %v, want %v", tt.display, got, tt.celsius)
+			}
+			if got := th.tempStr(tt.celsius); got != tt.str {
+				t.Errorf("tempStr(%v) = %q, want %q", tt.celsius, got, tt.str)
+			}
+		})
+	}
+}
+
+func TestThermostatFromDisplayRounds(t *testing.T) {
+	tests := []struct {
+		units   interface{}
+		display float32
+		want    float32
+	}{
+		{0.0, 21.34, 21.3},
+		{0.0, 21.36, 21.4},
+		{1.0, 71, 21.7},
+		{1.0, 72, 22.2},
+	}
+	for _, tt := range tests {
+		if got := newTestThermostat(tt.units).fromDisplay(tt.display); got != tt.want {
+			t.Errorf("units %v: fromDisplay(%v) = %v, want %v", tt.units, tt.display, got, tt.want)
+		}
+	}
+}
+
+func TestClampThresholds(t *testing.T) {
+	tests := []struct {
+		name             string
+		heat, cool       float32
+		heatDragged      bool
+		wantHeat, wantCl float32
+	}{
+		{"in order", 18, 24, true, 18, 24},
+		{"equal", 20, 20, false, 20, 20},
+		{"heat dragged above cool", 25, 24, true, 25, 25},
+		{"cool dragged below heat", 18, 16, false, 16, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heat, cool := clampThresholds(tt.heat, tt.cool, tt.heatDragged)
+			if heat != tt.wantHeat || cool != tt.wantCl {
+				t.Errorf("clampThresholds(%v, %v, %v) = %v, %v, want %v, %v",
+					tt.heat, tt.cool, tt.heatDragged, heat, cool, tt.wantHeat, tt.wantCl)
+			}
+		})
+	}
+}
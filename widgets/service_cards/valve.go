@@ -0,0 +1,445 @@
+package service_cards
+
+import (
+	"fmt"
+	"hkapp/applayout"
+	"hkapp/application"
+	"math"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+const durationDragDelay = 300 * time.Millisecond
+
+/*
+Valve
+Required Characteristics
+9.1 Active
+9.48 In Use : 0-Not in use 1-In use
+9.124 Valve Type : 0-Generic 1-Irrigation 2-Shower head 3-Water faucet
+
+Optional Characteristics
+9.95 Set Duration : 0-3600 seconds
+9.86 Remaining Duration : 0-3600 seconds
+9.47 Is Configured
+9.91 Service Label Index
+9.97 Status Fault
+9.62 Name
+
+Irrigation System
+Required Characteristics
+9.1 Active
+9.85 Program Mode : 0-No program 1-Scheduled 2-Scheduled, manual override
+9.48 In Use
+
+Optional Characteristics
+9.86 Remaining Duration
+9.62 Name
+9.97 Status Fault
+
+Faucet
+Required Characteristics
+9.1 Active
+
+Optional Characteristics
+9.62 Name
+9.97 Status Fault
+*/
+
+// countdown ticks locally between RemainingDuration updates
+type countdown struct {
+	remaining time.Duration
+	at        time.Time
+}
+
+func (c *countdown) set(v interface{}) {
+	if secs, ok := floatValue(v); ok {
+		c.remaining = time.Duration(secs) * time.Second
+		c.at = time.Now()
+	}
+}
+
+func (c *countdown) left(now time.Time) time.Duration {
+	l := c.remaining - now.Sub(c.at)
+	if l < 0 {
+		return 0
+	}
+	return l
+}
+
+type Valve struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	active         widget.Bool
+	quickActive    widget.Bool
+	durationWidget widget.Float
+	remaining      countdown
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewValve(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*Valve, error) {
+	v := &Valve{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	v.serviceChars.onValue = v.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	v.label = label
+
+	err = v.require(
+		hkontroller.CType_Active,
+		hkontroller.CType_InUse,
+		hkontroller.CType_ValveType)
+	if err != nil {
+		return nil, err
+	}
+	v.optional(
+		hkontroller.CType_SetDuration,
+		hkontroller.CType_RemainingDuration,
+		hkontroller.CType_IsConfigured,
+		hkontroller.CType_ServiceLabelIndex,
+		hkontroller.CType_StatusFault,
+		hkontroller.CType_Name)
+	v.fetch()
+
+	// valves of irrigation system are named by their own name or index
+	if name, ok := v.value(hkontroller.CType_Name).(string); ok && name != "" {
+		v.label = name
+	} else if idx, ok := intValue(v.value(hkontroller.CType_ServiceLabelIndex)); ok {
+		v.label = fmt.Sprintf("%s #%d", valveType2str(v.value(hkontroller.CType_ValveType)), idx)
+	}
+
+	return v, nil
+}
+
+func (v *Valve) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		v.active.Value = boolValue(value)
+		v.quickActive.Value = v.active.Value
+	case hkontroller.CType_SetDuration:
+		if d, ok := floatValue(value); ok {
+			v.durationWidget.Value = float32(d)
+		}
+	case hkontroller.CType_RemainingDuration:
+		v.remaining.set(value)
+	}
+}
+
+func (v *Valve) QuickAction() {
+	v.active.Value = !v.active.Value
+	v.quickActive.Value = v.active.Value
+	v.put(hkontroller.CType_Active, bool2num(v.active.Value))
+}
+
+// statusStr describes whether valve is running and how long it has left
+func (v *Valve) statusStr(gtx C) string {
+	if !boolValue(v.value(hkontroller.CType_InUse)) {
+		return "idle"
+	}
+	if !v.has(hkontroller.CType_RemainingDuration) {
+		return "in use"
+	}
+	left := v.remaining.left(gtx.Now)
+	if left > 0 {
+		// tick once a second until next RemainingDuration event
+		op.InvalidateOp{At: gtx.Now.Add(time.Second)}.Add(gtx.Ops)
+	}
+	return "in use, " + formatDuration(left)
+}
+
+func (v *Valve) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&v.quickActive, &v.active)
+	if v.active.Changed() {
+		v.put(hkontroller.CType_Active, bool2num(v.active.Value))
+	}
+	min, max, _ := v.valueRange(hkontroller.CType_SetDuration, 0, 3600, 1)
+	if sliderDragged(&v.durationWidget) {
+		v.putDebounced(hkontroller.CType_SetDuration, durationDragDelay, func() interface{} {
+			// whole minutes
+			return math.Round(float64(v.durationWidget.Value)/60) * 60
+		})
+	}
+
+	status := v.statusStr(gtx)
+
+	if v.quick {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(material.Switch(v.th, &v.quickActive, v.label).Layout),
+			layout.Rigid(material.Body1(v.th, " "+status).Layout),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(v.th, v.label).Layout,
+				material.Switch(v.th, &v.active, v.label).Layout)
+		}),
+		layout.Rigid(material.Body2(v.th, status).Layout),
+	)
+	if v.has(hkontroller.CType_SetDuration) {
+		durationStr := formatDuration(time.Duration(v.durationWidget.Value) * time.Second)
+		children = append(children,
+			layout.Rigid(material.Body2(v.th, "Duration: "+durationStr).Layout),
+			layout.Rigid(material.Slider(v.th, &v.durationWidget, float32(min), float32(max)).Layout))
+	}
+	if badges := statusBadges(v.th, v.serviceChars); len(badges) > 0 {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx, badges...)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// ValveGroup is IrrigationSystem or Faucet service with its linked valves
+type ValveGroup struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	active      widget.Bool
+	quickActive widget.Bool
+	remaining   countdown
+
+	valves []*Valve
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewIrrigationSystem(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ValveGroup, error) {
+	g, err := newValveGroup(app, acc, dev, s, quickWidget)
+	if err != nil {
+		return nil, err
+	}
+	err = g.require(
+		hkontroller.CType_ProgramMode,
+		hkontroller.CType_InUse)
+	if err != nil {
+		return nil, err
+	}
+	g.optional(hkontroller.CType_RemainingDuration)
+	g.fetch()
+
+	return g, nil
+}
+
+func NewFaucet(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ValveGroup, error) {
+	g, err := newValveGroup(app, acc, dev, s, quickWidget)
+	if err != nil {
+		return nil, err
+	}
+	g.fetch()
+
+	return g, nil
+}
+
+func newValveGroup(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*ValveGroup, error) {
+	g := &ValveGroup{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	g.serviceChars.onValue = g.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	g.label = label
+
+	err = g.require(hkontroller.CType_Active)
+	if err != nil {
+		return nil, err
+	}
+	g.optional(hkontroller.CType_StatusFault)
+
+	// valves are shown only on accessory page
+	if !quickWidget {
+		for _, vs := range linkedServices(acc, s, hkontroller.SType_Valve) {
+			v, err := NewValve(app, acc, dev, vs, false)
+			if err != nil {
+				continue
+			}
+			g.valves = append(g.valves, v)
+		}
+	}
+
+	return g, nil
+}
+
+func (g *ValveGroup) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	switch ctype {
+	case hkontroller.CType_Active:
+		g.active.Value = boolValue(value)
+		g.quickActive.Value = g.active.Value
+	case hkontroller.CType_RemainingDuration:
+		g.remaining.set(value)
+	}
+}
+
+// LinkedServices returns linked valves rendered by this card
+func (g *ValveGroup) LinkedServices() []*hkontroller.ServiceDescription {
+	res := make([]*hkontroller.ServiceDescription, 0, len(g.valves))
+	for _, v := range g.valves {
+		res = append(res, v.srv)
+	}
+	return res
+}
+
+func (g *ValveGroup) SubscribeToEvents() {
+	g.serviceChars.SubscribeToEvents()
+	for _, v := range g.valves {
+		v.SubscribeToEvents()
+	}
+}
+
+func (g *ValveGroup) UnsubscribeFromEvents() {
+	g.serviceChars.UnsubscribeFromEvents()
+	for _, v := range g.valves {
+		v.UnsubscribeFromEvents()
+	}
+}
+
+func (g *ValveGroup) QuickAction() {
+	g.active.Value = !g.active.Value
+	g.quickActive.Value = g.active.Value
+	g.put(hkontroller.CType_Active, bool2num(g.active.Value))
+}
+
+func (g *ValveGroup) Layout(gtx C) D {
+
+	mirrorQuickSwitch(&g.quickActive, &g.active)
+	if g.active.Changed() {
+		g.put(hkontroller.CType_Active, bool2num(g.active.Value))
+	}
+
+	var status string
+	if g.has(hkontroller.CType_InUse) {
+		status = "idle"
+		if boolValue(g.value(hkontroller.CType_InUse)) {
+			status = "in use"
+			if g.has(hkontroller.CType_RemainingDuration) {
+				left := g.remaining.left(gtx.Now)
+				if left > 0 {
+					op.InvalidateOp{At: gtx.Now.Add(time.Second)}.Add(gtx.Ops)
+				}
+				status += ", " + formatDuration(left)
+			}
+		}
+	}
+	if g.has(hkontroller.CType_ProgramMode) {
+		status += " | " + programMode2str(g.value(hkontroller.CType_ProgramMode))
+	}
+
+	if g.quick {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(material.Switch(g.th, &g.quickActive, g.label).Layout),
+			layout.Rigid(material.Body1(g.th, " "+status).Layout),
+		)
+	}
+
+	var children []layout.FlexChild
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			return applayout.DetailRow{PrimaryWidth: 0.8}.Layout(gtx,
+				material.Body1(g.th, g.label).Layout,
+				material.Switch(g.th, &g.active, g.label).Layout)
+		}),
+		layout.Rigid(material.Body2(g.th, status).Layout),
+	)
+	if badges := statusBadges(g.th, g.serviceChars); len(badges) > 0 {
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx, badges...)
+			}))
+	}
+	for _, v := range g.valves {
+		v := v
+		children = append(children,
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(8), Left: unit.Dp(16)}.Layout(gtx, v.Layout)
+			}))
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// util
+// ------------------
+func formatDuration(d time.Duration) string {
+	secs := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+func valveType2str(v interface{}) string {
+	valStr := "Valve"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 1:
+			valStr = "Zone"
+		case 2:
+			valStr = "Shower head"
+		case 3:
+			valStr = "Faucet"
+		}
+	}
+	return valStr
+}
+func programMode2str(v interface{}) string {
+	valStr := "unknown"
+	if m, ok := intValue(v); ok {
+		switch m {
+		case 0:
+			valStr = "no program"
+		case 1:
+			valStr = "scheduled"
+		case 2:
+			valStr = "manual override"
+		}
+	}
+	return valStr
+}
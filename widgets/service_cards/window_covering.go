@@ -0,0 +1,109 @@
+package service_cards
+
+import (
+	"hkapp/application"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/hkontrol/hkontroller"
+)
+
+/*
+Required Characteristics
+9.27 Current Position : 0-100
+9.117 Target Position : 0-100
+9.78 Position State : 0-Decreasing 1-Increasing 2-Stopped
+
+Optional Characteristics
+9.45 Hold Position
+9.118 Target Horizontal Tilt Angle : -90-90
+9.123 Target Vertical Tilt Angle : -90-90
+9.28 Current Horizontal Tilt Angle : -90-90
+9.29 Current Vertical Tilt Angle : -90-90
+9.65 Obstruction Detected
+*/
+
+type WindowCovering struct {
+	quick bool // simplified version to display in list of accs
+
+	label string
+
+	*serviceChars
+
+	pos *positionControl
+
+	th *material.Theme
+
+	*application.App
+}
+
+func NewWindowCovering(app *application.App,
+	acc *hkontroller.Accessory,
+	dev *hkontroller.Device,
+	s *hkontroller.ServiceDescription,
+	quickWidget bool) (*WindowCovering, error) {
+	w := &WindowCovering{
+		quick:        quickWidget,
+		th:           app.Theme,
+		App:          app,
+		serviceChars: newServiceChars(app, acc, dev, s),
+	}
+	w.serviceChars.onValue = w.onValue
+
+	label, err := accessoryName(acc)
+	if err != nil {
+		return nil, err
+	}
+	w.label = label
+
+	err = w.require(
+		hkontroller.CType_CurrentPosition,
+		hkontroller.CType_TargetPosition,
+		hkontroller.CType_PositionState)
+	if err != nil {
+		return nil, err
+	}
+	w.optional(
+		hkontroller.CType_HoldPosition,
+		hkontroller.CType_CurrentHorizontalTiltAngle,
+		hkontroller.CType_TargetHorizontalTiltAngle,
+		hkontroller.CType_CurrentVerticalTiltAngle,
+		hkontroller.CType_TargetVerticalTiltAngle,
+		hkontroller.CType_ObstructionDetected)
+	w.pos = newPositionControl(w.serviceChars, w.th)
+	w.fetch()
+
+	return w, nil
+}
+
+func (w *WindowCovering) onValue(value interface{}, ctype hkontroller.HapCharacteristicType, source application.Source) {
+	w.pos.onValue(value, ctype, source)
+}
+
+// QuickAction fully opens covering, or closes it if it is open or opening
+func (w *WindowCovering) QuickAction() {
+	w.pos.quickAction()
+}
+
+func (w *WindowCovering) Layout(gtx C) D {
+
+	w.pos.update()
+
+	children := w.pos.layout(w.quick)
+
+	return widget.Border{
+		Color: color.NRGBA{
+			R: 0,
+			G: 0,
+			B: 0,
+			A: 0,
+		},
+		Width:        unit.Dp(1),
+		CornerRadius: unit.Dp(1),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	})
+}